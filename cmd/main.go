@@ -17,10 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
-	"net/http"
+	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -28,6 +31,8 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -37,11 +42,17 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	scannerv1 "github.com/kerezsiz42/scanner-operator2/api/v1"
+	"github.com/kerezsiz42/scanner-operator2/internal/config"
 	"github.com/kerezsiz42/scanner-operator2/internal/controller"
 	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"github.com/kerezsiz42/scanner-operator2/internal/events"
+	"github.com/kerezsiz42/scanner-operator2/internal/metrics"
 	"github.com/kerezsiz42/scanner-operator2/internal/oapi"
 	"github.com/kerezsiz42/scanner-operator2/internal/server"
 	"github.com/kerezsiz42/scanner-operator2/internal/service"
+	"github.com/kerezsiz42/scanner-operator2/internal/storage"
+	"github.com/kerezsiz42/scanner-operator2/internal/tasks"
+	"github.com/kerezsiz42/scanner-operator2/internal/tracing"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -58,6 +69,11 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(os.Args[2:])
+		return
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
@@ -74,6 +90,8 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	cfg := config.Default()
+	cfg.BindFlags(flag.CommandLine)
 	opts := zap.Options{
 		Development: true,
 	}
@@ -82,6 +100,16 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if err := cfg.Load(flag.CommandLine); err != nil {
+		mainLog.Error(err, "unable to load configuration")
+		os.Exit(1)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		mainLog.Error(err, "invalid configuration")
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -126,33 +154,68 @@ func main() {
 	}
 
 	// Custom Logic Start
-	jobObjectService, err := service.NewJobObjectService()
+	// The context is cancelled on SIGTERM, which also interrupts the
+	// migrations.
+	ctx := ctrl.SetupSignalHandler()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
 	if err != nil {
-		mainLog.Error(err, "unable to create JobObjectService")
+		mainLog.Error(err, "unable to set up tracing")
 		os.Exit(1)
 	}
 
 	mainLog.Info("connecting to database")
-	db, err := database.GetDatabase()
+	db, err := database.GetDatabase(cfg.Database, mainLog.WithName("database"))
 	if err != nil {
 		mainLog.Error(err, "unable to connect to database")
 		os.Exit(1)
 	}
 
-	scanService := service.NewScanService(db)
-
-	s := &http.Server{
-		Handler: oapi.Handler(server.NewServer(scanService, mainLog)),
-		Addr:    ":8000",
+	reportStore, err := storage.GetReportStore(db, cfg.ReportStore)
+	if err != nil {
+		mainLog.Error(err, "unable to create report store")
+		os.Exit(1)
 	}
 
-	go func() {
-		mainLog.Info("starting Scanner API HTTP server")
-		if err := s.ListenAndServe(); err != http.ErrServerClosed {
-			mainLog.Error(err, "unable to start Scanner API HTTP server")
+	if cfg.AutoMigrate {
+		migrations, err := database.Migrate(ctx, db, service.Backfills(reportStore), false)
+		if err != nil {
+			mainLog.Error(err, "unable to migrate database")
 			os.Exit(1)
 		}
-	}()
+
+		for _, migration := range migrations {
+			mainLog.Info("applied database migration", "version", migration.Version, "name", migration.Name)
+		}
+	}
+
+	scanService := service.NewScanService(db, reportStore)
+	if err := metrics.RegisterScanResultCollector(scanService, cfg.MetricsMaxImages); err != nil {
+		mainLog.Error(err, "unable to register scan result metrics")
+		os.Exit(1)
+	}
+
+	uploadTokenService, err := service.NewUploadTokenService(db, []byte(cfg.ScanJob.UploadTokenKey))
+	if err != nil {
+		mainLog.Error(err, "unable to create upload token service")
+		os.Exit(1)
+	}
+
+	// Validate has checked the port of the bind address already.
+	apiPort, _ := cfg.API.Port()
+	jobObjectOptions := service.JobObjectOptions{
+		APIServiceHostname: cfg.ScanJob.APIServiceHostname,
+		APIPort:            apiPort,
+	}
+	if cfg.API.CertDir != "" {
+		jobObjectOptions.CAFile = filepath.Join(cfg.API.CertDir, cfg.API.CAName)
+	}
+
+	jobObjectService, err := service.NewJobObjectService(jobObjectOptions, uploadTokenService)
+	if err != nil {
+		mainLog.Error(err, "unable to create JobObjectService")
+		os.Exit(1)
+	}
 	// Custom Logic End
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
@@ -179,11 +242,130 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Custom Logic Start
+	workloadService := service.NewWorkloadService(mgr.GetClient())
+	bus, err := events.GetBus(db, cfg.EventBus.Options())
+	if err != nil {
+		mainLog.Error(err, "unable to create event bus")
+		os.Exit(1)
+	}
+
+	var apiAuthenticator authenticator.Request
+	var apiAuthorizer authorizer.Authorizer
+	if cfg.API.Auth {
+		apiAuthenticator, apiAuthorizer, err = server.NewDelegatingAuth(mgr.GetConfig(), mgr.GetHTTPClient())
+		if err != nil {
+			mainLog.Error(err, "unable to create Scanner API authentication")
+			os.Exit(1)
+		}
+	}
+
+	apiServer := server.NewServer(scanService, workloadService, uploadTokenService, apiAuthorizer, bus, mainLog)
+
+	databaseHealth, err := database.NewHealthChecker(db, 5*time.Second, mainLog.WithName("database"))
+	if err != nil {
+		mainLog.Error(err, "unable to create database health checker")
+		os.Exit(1)
+	}
+
+	apiHandler := server.WithDatabaseAvailability(databaseHealth, 10*time.Second, oapi.HandlerWithOptions(apiServer, oapi.StdHTTPServerOptions{
+		Middlewares:      []oapi.MiddlewareFunc{server.NameSpanAfterRoute},
+		ErrorHandlerFunc: server.ParameterErrorHandler,
+	}))
+	if cfg.API.Auth {
+		apiHandler = server.WithAuth(apiAuthenticator, apiAuthorizer, mainLog, apiHandler)
+	}
+
+	apiHandler = server.WithTracing(server.WithRequestLogger(mainLog, apiHandler))
+
+	busType := cfg.EventBus.Type
+	if enableLeaderElection && !cfg.API.LeaderElection && busType != events.Postgres && busType != events.Polling {
+		mainLog.Info("the events of the leader only reach the subscribers of the other replicas with a " +
+			"postgres or polling event bus")
+	}
+
+	apiHTTPServer := server.NewHTTPServer(apiServer, apiHandler, server.HTTPServerOptions{
+		BindAddress:     cfg.API.BindAddress,
+		LeaderElection:  cfg.API.LeaderElection,
+		CertDir:         cfg.API.CertDir,
+		ClientCAName:    cfg.API.ClientCAName,
+		TLSOpts:         tlsOpts,
+		ReadTimeout:     30 * time.Second,
+		WriteTimeout:    60 * time.Second,
+		IdleTimeout:     120 * time.Second,
+		ShutdownTimeout: 20 * time.Second,
+	}, mainLog)
+	if err := mgr.Add(apiHTTPServer); err != nil {
+		mainLog.Error(err, "unable to add Scanner API HTTP server")
+		os.Exit(1)
+	}
+
+	// Every replica keeps checking the database, so that the API and the
+	// reconciler back off while it is unavailable between the readiness
+	// probes.
+	if err := mgr.Add(&tasks.PeriodicTask{
+		Name:     "database-health",
+		Interval: 10 * time.Second,
+		Run: func(ctx context.Context) error {
+			// The changes of the availability are logged by the checker.
+			_ = databaseHealth.Check(ctx)
+			return nil
+		},
+		Logger: mainLog,
+	}); err != nil {
+		mainLog.Error(err, "unable to add database health check")
+		os.Exit(1)
+	}
+
+	// The maintenance of the database and the rescans are singletons, so
+	// they run on the leader only.
+	if err := mgr.Add(&tasks.PeriodicTask{
+		Name:           "database-maintenance",
+		Interval:       10 * time.Minute,
+		LeaderElection: true,
+		Run: func(ctx context.Context) error {
+			pruned, err := uploadTokenService.PruneUsedTokens(ctx)
+			if pruned > 0 {
+				mainLog.Info("pruned used upload tokens", "count", pruned)
+			}
+
+			return err
+		},
+		Logger: mainLog,
+	}); err != nil {
+		mainLog.Error(err, "unable to add database maintenance")
+		os.Exit(1)
+	}
+
+	if rescanAfter := cfg.RescanAfter.Duration; rescanAfter > 0 {
+		if err := mgr.Add(&tasks.PeriodicTask{
+			Name:           "rescan",
+			Interval:       min(rescanAfter, 10*time.Minute),
+			LeaderElection: true,
+			Run: func(ctx context.Context) error {
+				requested, err := scanService.RequestRescans(ctx, time.Now().Add(-rescanAfter))
+				if requested > 0 {
+					mainLog.Info("requested rescans", "count", requested)
+				}
+
+				return err
+			},
+			Logger: mainLog,
+		}); err != nil {
+			mainLog.Error(err, "unable to add rescans")
+			os.Exit(1)
+		}
+	}
+	// Custom Logic End
+
 	if err = (&controller.ScannerReconciler{
-		Client:           mgr.GetClient(),
-		Scheme:           mgr.GetScheme(),
-		JobObjectService: jobObjectService,
-		ScanService:      scanService,
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		JobObjectService:   jobObjectService,
+		ScanService:        scanService,
+		Events:             apiServer,
+		ScanJobClusterRole: cfg.ScanJob.ClusterRole,
+		Database:           databaseHealth,
 	}).SetupWithManager(mgr); err != nil {
 		mainLog.Error(err, "unable to create controller", "controller", "Scanner")
 		os.Exit(1)
@@ -198,10 +380,90 @@ func main() {
 		mainLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	// The liveness check stays a ping, as restarting does not bring the
+	// database back.
+	if err := mgr.AddReadyzCheck("database", databaseHealth.ReadyzCheck); err != nil {
+		mainLog.Error(err, "unable to set up database ready check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("api", apiHTTPServer.ReadyzCheck); err != nil {
+		mainLog.Error(err, "unable to set up Scanner API ready check")
+		os.Exit(1)
+	}
 
 	mainLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	err = mgr.Start(ctx)
+
+	// The context is cancelled by now, the spans left are flushed with a
+	// fresh one.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		mainLog.Error(err, "failed to flush spans")
+	}
+	cancel()
+
+	if err != nil {
 		mainLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// migrate runs the database migrations without starting the operator, e.g.
+// from a Job before upgrading the Deployment.
+func migrate(args []string) {
+	var dryRun bool
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.BoolVar(&dryRun, "dry-run", false, "If set, pending migrations are listed without being applied.")
+	cfg := config.Default()
+	cfg.BindFlags(fs)
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(fs)
+	_ = fs.Parse(args)
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	migrateLog := ctrl.Log.WithName("migrate")
+
+	if err := cfg.Load(fs); err != nil {
+		migrateLog.Error(err, "unable to load configuration")
+		os.Exit(1)
+	}
+
+	if err := cfg.ValidateStorage(); err != nil {
+		migrateLog.Error(err, "invalid configuration")
+		os.Exit(1)
+	}
+
+	migrateLog.Info("connecting to database")
+	db, err := database.GetDatabase(cfg.Database, migrateLog.WithName("database"))
+	if err != nil {
+		migrateLog.Error(err, "unable to connect to database")
+		os.Exit(1)
+	}
+
+	reportStore, err := storage.GetReportStore(db, cfg.ReportStore)
+	if err != nil {
+		migrateLog.Error(err, "unable to create report store")
+		os.Exit(1)
+	}
+
+	migrations, err := database.Migrate(ctrl.SetupSignalHandler(), db, service.Backfills(reportStore), dryRun)
+	if err != nil {
+		migrateLog.Error(err, "unable to migrate database")
+		os.Exit(1)
+	}
+
+	for _, migration := range migrations {
+		if dryRun {
+			migrateLog.Info("pending database migration", "version", migration.Version, "name", migration.Name)
+			fmt.Printf("-- %04d_%s\n%s\n", migration.Version, migration.Name, migration.SQL)
+		} else {
+			migrateLog.Info("applied database migration", "version", migration.Version, "name", migration.Name)
+		}
+	}
+
+	if len(migrations) == 0 {
+		migrateLog.Info("database schema is up to date")
+	}
+}
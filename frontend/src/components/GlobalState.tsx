@@ -4,7 +4,7 @@ import React, {
   useCallback,
   useReducer,
 } from "react";
-import { useSubscriber } from "../hooks/useSubscriber";
+import { SubscriptionEvent, useSubscriber } from "../hooks/useSubscriber";
 import { components } from "../oapi.gen";
 
 type ScanResult = components["schemas"]["ScanResult"];
@@ -77,8 +77,22 @@ export const GlobalStateProvider = ({ children }: GlobalStateProviderProps) => {
   const [state, dispatch] = useReducer(globalReducer, initialState);
 
   const onMessage = useCallback(
-    async (imageId: string) => {
-      const res = await fetch(`/scan-results/${encodeURIComponent(imageId)}`);
+    async (event: SubscriptionEvent) => {
+      if (event.type === "scanResultDeleted") {
+        dispatch({ type: "remove", payload: event.imageId });
+        return;
+      }
+
+      if (
+        event.type !== "scanResultCreated" &&
+        event.type !== "scanResultUpdated"
+      ) {
+        return;
+      }
+
+      const res = await fetch(
+        `/scan-results/${encodeURIComponent(event.imageId)}`
+      );
       if (!res.ok) {
         return;
       }
@@ -92,12 +106,23 @@ export const GlobalStateProvider = ({ children }: GlobalStateProviderProps) => {
   const onConnection = useCallback(
     async (isConnected: boolean) => {
       if (isConnected) {
-        const res = await fetch(`/scan-results`);
-        if (!res.ok) {
-          return;
-        }
+        const scanResults: ScanResult[] = [];
+        let cursor: string | null = null;
+        do {
+          const params = new URLSearchParams({ view: "summary", limit: "1000" });
+          if (cursor) {
+            params.set("cursor", cursor);
+          }
+
+          const res = await fetch(`/scan-results?${params}`);
+          if (!res.ok) {
+            return;
+          }
+
+          scanResults.push(...((await res.json()) as ScanResult[]));
+          cursor = res.headers.get("X-Next-Cursor");
+        } while (cursor);
 
-        const scanResults = (await res.json()) as ScanResult[];
         dispatch({ type: "connection_gained", payload: scanResults });
       } else {
         dispatch({ type: "connection_lost" });
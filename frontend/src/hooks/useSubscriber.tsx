@@ -1,18 +1,34 @@
 import { useEffect } from "react";
 import { Subscriber } from "../subscriber";
 
+export type SubscriptionEvent = {
+  version: number;
+  type:
+    | "scanResultCreated"
+    | "scanResultUpdated"
+    | "scanResultDeleted"
+    | "scanStarted"
+    | "scanFailed";
+  imageId: string;
+  namespaces: string[];
+  timestamp: string;
+  sequence: number;
+};
+
 export type UseSubscriberProps = {
-  onMessage: (value: string) => void;
+  onMessage: (value: SubscriptionEvent) => void;
   onConnection: (value: boolean) => void;
 };
 
 export function useSubscriber({ onMessage, onConnection }: UseSubscriberProps) {
   useEffect(() => {
     const ac = new AbortController();
-    const s = new Subscriber("/subscribe", { signal: ac.signal });
+    const s = new Subscriber("/subscribe?format=envelope", {
+      signal: ac.signal,
+    });
 
     s.addEventListener("message", (e: CustomEventInit) => {
-      onMessage(e.detail as string);
+      onMessage(e.detail as SubscriptionEvent);
     });
 
     s.addEventListener("connection", (e: CustomEventInit) => {
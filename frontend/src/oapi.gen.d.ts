@@ -13,29 +13,56 @@ export interface paths {
         };
         get: {
             parameters: {
-                query?: never;
+                query?: {
+                    /** @description Maximum number of ScanResults in the response. */
+                    limit?: number;
+                    /** @description Continues the listing after the last ScanResult of the previous page, as returned in the X-Next-Cursor header. */
+                    cursor?: string;
+                    /** @description Only ScanResults of images which were running in the namespace when they were scanned. */
+                    namespace?: string;
+                    /** @description Only ScanResults with at least one vulnerability of this severity or higher. */
+                    minSeverity?: components["schemas"]["Severity"];
+                    /** @description Only ScanResults with (true) or without (false) a vulnerability which has a fix available. */
+                    hasFix?: boolean;
+                    /** @description Only ScanResults of images from the repository, e.g. docker.io/library/alpine. */
+                    repository?: string;
+                    /** @description Only ScanResults whose latest report was uploaded at or after this time. */
+                    scannedSince?: string;
+                    sort?: "imageId" | "createdAt" | "updatedAt";
+                    order?: "asc" | "desc";
+                    /** @description The summary view leaves out the reports. */
+                    view?: "full" | "summary";
+                };
                 header?: never;
                 path?: never;
                 cookie?: never;
             };
             requestBody?: never;
             responses: {
-                /** @description Responds with all ScanResults. */
+                /** @description Responds with a page of ScanResults. */
                 200: {
                     headers: {
+                        /** @description is the cursor of the next page, missing on the last page. */
+                        "X-Next-Cursor"?: string;
                         [name: string]: unknown;
                     };
                     content: {
                         "application/json": components["schemas"]["ScanResult"][];
                     };
                 };
+                400: components["responses"]["BadRequest"];
+                401: components["responses"]["Unauthorized"];
+                500: components["responses"]["InternalServerError"];
             };
         };
-        put?: never;
-        post: {
+        put: {
             parameters: {
                 query?: never;
-                header?: never;
+                header?: {
+                    /** @description is the one-time token of the scan Job, which is only accepted for the image it was issued for.
+                     *      */
+                    "X-Upload-Token"?: string;
+                };
                 path?: never;
                 cookie?: never;
             };
@@ -45,8 +72,8 @@ export interface paths {
                 };
             };
             responses: {
-                /** @description ScanResult created successfully. */
-                201: {
+                /** @description ScanResult upserted successfully. */
+                200: {
                     headers: {
                         [name: string]: unknown;
                     };
@@ -54,15 +81,30 @@ export interface paths {
                         "application/json": components["schemas"]["ScanResult"];
                     };
                 };
-                /** @description Invalid input. */
-                400: {
+                400: components["responses"]["BadRequest"];
+                401: components["responses"]["Unauthorized"];
+                /** @description The upload token is missing, expired, used already or issued for another image. */
+                403: {
                     headers: {
                         [name: string]: unknown;
                     };
-                    content?: never;
+                    content: {
+                        "application/problem+json": components["schemas"]["Problem"];
+                    };
+                };
+                /** @description The ScanResult is larger than the server accepts. */
+                413: {
+                    headers: {
+                        [name: string]: unknown;
+                    };
+                    content: {
+                        "application/problem+json": components["schemas"]["Problem"];
+                    };
                 };
+                500: components["responses"]["InternalServerError"];
             };
         };
+        post?: never;
         delete?: never;
         options?: never;
         head?: never;
@@ -96,20 +138,95 @@ export interface paths {
                         "application/json": components["schemas"]["ScanResult"];
                     };
                 };
-                /** @description ScanResult not found. */
-                404: {
+                401: components["responses"]["Unauthorized"];
+                404: components["responses"]["NotFound"];
+                500: components["responses"]["InternalServerError"];
+            };
+        };
+        put?: never;
+        post?: never;
+        delete: {
+            parameters: {
+                query?: never;
+                header?: never;
+                path: {
+                    imageId: string;
+                };
+                cookie?: never;
+            };
+            requestBody?: never;
+            responses: {
+                /** @description ScanResult deleted successfully. The scan history of the image is kept. */
+                204: {
                     headers: {
                         [name: string]: unknown;
                     };
                     content?: never;
                 };
+                401: components["responses"]["Unauthorized"];
+                500: components["responses"]["InternalServerError"];
+            };
+        };
+        options?: never;
+        head?: never;
+        patch?: never;
+        trace?: never;
+    };
+    "/scan-results/{imageId}/scans": {
+        parameters: {
+            query?: never;
+            header?: never;
+            path?: never;
+            cookie?: never;
+        };
+        get: {
+            parameters: {
+                query?: never;
+                header?: never;
+                path: {
+                    imageId: string;
+                };
+                cookie?: never;
+            };
+            requestBody?: never;
+            responses: {
+                /** @description Responds with the scan history of the image from the newest to the oldest scan. */
+                200: {
+                    headers: {
+                        [name: string]: unknown;
+                    };
+                    content: {
+                        "application/json": components["schemas"]["Scan"][];
+                    };
+                };
+                401: components["responses"]["Unauthorized"];
+                404: components["responses"]["NotFound"];
+                500: components["responses"]["InternalServerError"];
             };
         };
         put?: never;
         post?: never;
-        delete: {
+        delete?: never;
+        options?: never;
+        head?: never;
+        patch?: never;
+        trace?: never;
+    };
+    "/scan-results/{imageId}/diff": {
+        parameters: {
+            query?: never;
+            header?: never;
+            path?: never;
+            cookie?: never;
+        };
+        get: {
             parameters: {
-                query?: never;
+                query?: {
+                    /** @description ID of the scan to compare against. Defaults to the scan preceding the target scan. */
+                    from?: number;
+                    /** @description ID of the target scan. Defaults to the latest scan of the image. */
+                    to?: number;
+                };
                 header?: never;
                 path: {
                     imageId: string;
@@ -118,21 +235,30 @@ export interface paths {
             };
             requestBody?: never;
             responses: {
-                /** @description ScanResult deleted successfully. */
-                204: {
+                /** @description Responds with the vulnerabilities introduced, fixed and changed in severity between the two scans. */
+                200: {
                     headers: {
                         [name: string]: unknown;
                     };
-                    content?: never;
+                    content: {
+                        "application/json": components["schemas"]["ScanDiff"];
+                    };
                 };
+                400: components["responses"]["BadRequest"];
+                401: components["responses"]["Unauthorized"];
+                404: components["responses"]["NotFound"];
+                500: components["responses"]["InternalServerError"];
             };
         };
+        put?: never;
+        post?: never;
+        delete?: never;
         options?: never;
         head?: never;
         patch?: never;
         trace?: never;
     };
-    "/subscribe": {
+    "/vulnerabilities/{vulnerabilityId}/images": {
         parameters: {
             query?: never;
             header?: never;
@@ -143,13 +269,108 @@ export interface paths {
             parameters: {
                 query?: never;
                 header?: never;
+                path: {
+                    /** @description Identifier of the advisory or one of its aliases, e.g. a CVE or GHSA ID. */
+                    vulnerabilityId: string;
+                };
+                cookie?: never;
+            };
+            requestBody?: never;
+            responses: {
+                /** @description Responds with the images affected by the vulnerability and the workloads running them. */
+                200: {
+                    headers: {
+                        [name: string]: unknown;
+                    };
+                    content: {
+                        "application/json": components["schemas"]["ImageMatch"][];
+                    };
+                };
+                401: components["responses"]["Unauthorized"];
+                500: components["responses"]["InternalServerError"];
+            };
+        };
+        put?: never;
+        post?: never;
+        delete?: never;
+        options?: never;
+        head?: never;
+        patch?: never;
+        trace?: never;
+    };
+    "/components": {
+        parameters: {
+            query?: never;
+            header?: never;
+            path?: never;
+            cookie?: never;
+        };
+        get: {
+            parameters: {
+                query: {
+                    /** @description Package URL to look for. Without a version every version of the package matches. */
+                    purl: string;
+                };
+                header?: never;
                 path?: never;
                 cookie?: never;
             };
             requestBody?: never;
             responses: {
-                /** @description Open a websocket connection which sends ScanResult imageIds when a new object
-                 *     is inserted into the db in order to enable the client to fetch them as soon as possible.
+                /** @description Responds with the images containing the component and the workloads running them. */
+                200: {
+                    headers: {
+                        [name: string]: unknown;
+                    };
+                    content: {
+                        "application/json": components["schemas"]["ImageMatch"][];
+                    };
+                };
+                400: components["responses"]["BadRequest"];
+                401: components["responses"]["Unauthorized"];
+                500: components["responses"]["InternalServerError"];
+            };
+        };
+        put?: never;
+        post?: never;
+        delete?: never;
+        options?: never;
+        head?: never;
+        patch?: never;
+        trace?: never;
+    };
+    "/subscribe": {
+        parameters: {
+            query?: never;
+            header?: never;
+            path?: never;
+            cookie?: never;
+        };
+        get: {
+            parameters: {
+                query?: {
+                    /** @description selects the format of the messages. The envelope format sends every change as an Event,
+                     *     the legacy format only sends the quoted imageIds of the inserted and updated ScanResults.
+                     *      */
+                    format?: "envelope" | "legacy";
+                    /** @description Only Events of images running in one of the namespaces. */
+                    namespace?: components["parameters"]["EventNamespaces"];
+                    /** @description Only Events of images pulled by digest from one of the repositories. */
+                    repository?: components["parameters"]["EventRepositories"];
+                    minSeverity?: components["parameters"]["EventMinSeverity"];
+                    /** @description Only Events of these types. */
+                    type?: components["parameters"]["EventTypes"];
+                };
+                header?: never;
+                path?: never;
+                cookie?: never;
+            };
+            requestBody?: never;
+            responses: {
+                /** @description Open a websocket connection which sends an Event when a ScanResult is created, updated
+                 *     or deleted, or when a scan is started or fails, in order to enable the client to fetch
+                 *     the changes as soon as possible. The client can replace the filters given as query
+                 *     parameters by sending an EventFilter message.
                  *      */
                 101: {
                     headers: {
@@ -157,6 +378,65 @@ export interface paths {
                     };
                     content?: never;
                 };
+                400: components["responses"]["BadRequest"];
+                401: components["responses"]["Unauthorized"];
+                503: components["responses"]["ServiceUnavailable"];
+            };
+        };
+        put?: never;
+        post?: never;
+        delete?: never;
+        options?: never;
+        head?: never;
+        patch?: never;
+        trace?: never;
+    };
+    "/events": {
+        parameters: {
+            query?: never;
+            header?: never;
+            path?: never;
+            cookie?: never;
+        };
+        get: {
+            parameters: {
+                query?: {
+                    /** @description Only Events of images running in one of the namespaces. */
+                    namespace?: components["parameters"]["EventNamespaces"];
+                    /** @description Only Events of images pulled by digest from one of the repositories. */
+                    repository?: components["parameters"]["EventRepositories"];
+                    minSeverity?: components["parameters"]["EventMinSeverity"];
+                    /** @description Only Events of these types. */
+                    type?: components["parameters"]["EventTypes"];
+                };
+                header?: {
+                    /** @description is the id of the last message received. The Events published since then are sent
+                     *     first if the server still has them, otherwise a reset event is sent, e.g. when the id
+                     *     was sent by another replica or before the server was restarted.
+                     *      */
+                    "Last-Event-ID"?: string;
+                };
+                path?: never;
+                cookie?: never;
+            };
+            requestBody?: never;
+            responses: {
+                /** @description Opens a Server-Sent Events stream of the same Events as /subscribe, for clients which
+                 *     can not use websockets. The id of every message identifies the Event on every replica
+                 *     of the server, and its data is the Event. A message with the event name reset tells the
+                 *     client that it missed Events and has to reload the ScanResults.
+                 *      */
+                200: {
+                    headers: {
+                        [name: string]: unknown;
+                    };
+                    content: {
+                        "text/event-stream": string;
+                    };
+                };
+                400: components["responses"]["BadRequest"];
+                401: components["responses"]["Unauthorized"];
+                503: components["responses"]["ServiceUnavailable"];
             };
         };
         put?: never;
@@ -279,12 +559,261 @@ export interface components {
         ScanResult: {
             /** @example alpine@sha256:beefdbd8a1da6d2915566fde36db9db0b524eb737fc57cd1367effd16dc0d06d */
             imageId: string;
-            /** @description A big piece of JSON string which should conform to the CycloneDX BOM schema. */
-            report: string;
+            /** @description is a big JSON object which should conform to the CycloneDX BOM schema. It is required when uploading and left out of the summary view. */
+            report?: Record<string, never>;
+            /**
+             * Format: date-time
+             * @description is the time the image was first scanned.
+             */
+            readonly createdAt?: string;
+            /**
+             * Format: date-time
+             * @description is the time the latest report of the image was uploaded.
+             */
+            readonly updatedAt?: string;
+            /**
+             * @description defaults to the tool recorded in the metadata of the report if neither scannerName nor scannerVersion is set.
+             * @example grype
+             */
+            scannerName?: string;
+            /** @example 0.83.0 */
+            scannerVersion?: string;
+            /**
+             * @description is the version of the vulnerability database used by the scanner.
+             * @example 2024-10-10T01:31:29Z
+             */
+            databaseVersion?: string;
+            /**
+             * Format: double
+             * @description is the time elapsed between the creation of the scan Job and the upload of the report.
+             */
+            scanDurationSeconds?: number;
+            /** @description are the containers which were running the image when the scan was started. */
+            triggeredBy?: components["schemas"]["ScanTrigger"][];
+            summary?: components["schemas"]["VulnerabilitySummary"];
+        };
+        /** @description counts the findings of the report by severity. */
+        VulnerabilitySummary: {
+            critical: number;
+            high: number;
+            medium: number;
+            low: number;
+            /** @description includes the findings of severity none. */
+            info: number;
+            unknown: number;
+            /** @description is the number of findings for which the scanner recommends an upgrade. */
+            fixable: number;
+            /**
+             * Format: double
+             * @description is the highest CVSS score of the vulnerabilities, 0 if none is rated.
+             * @example 9.8
+             */
+            maxCvssScore: number;
+            /** @description is the number of components in the report. */
+            components: number;
+        };
+        /** @description is a change of a ScanResult or of a scan sent on the /subscribe websocket. */
+        Event: {
+            /**
+             * @description is the version of the envelope, increased on incompatible changes.
+             * @example 1
+             */
+            version: number;
+            type: components["schemas"]["EventType"];
+            imageId: string;
+            /** @description are the namespaces in which the image was running when it was scanned. */
+            namespaces: string[];
+            summary?: components["schemas"]["VulnerabilitySummary"];
+            /** Format: date-time */
+            timestamp: string;
+            /**
+             * Format: int64
+             * @description increases by one with every Event sent by the server.
+             */
+            sequence: number;
+        };
+        /** @enum {string} */
+        EventType: "scanResultCreated" | "scanResultUpdated" | "scanResultDeleted" | "scanStarted" | "scanFailed";
+        /** @description selects the Events a subscriber receives. Omitted or empty fields match every Event.
+         *      */
+        EventFilter: {
+            /** @description Only Events of images running in one of the namespaces. */
+            namespaces?: string[];
+            /** @description Only Events of images pulled by digest from one of the repositories. */
+            repositories?: string[];
+            minSeverity?: components["schemas"]["Severity"];
+            types?: components["schemas"]["EventType"][];
+        };
+        /**
+         * @description Only Events of images with at least one vulnerability of this severity or higher. Events
+         *     without a summary are not filtered by severity.
+         *     
+         * @enum {string}
+         */
+        Severity: "none" | "info" | "low" | "medium" | "high" | "critical";
+        ScanTrigger: {
+            namespace: string;
+            pod: string;
+            container: string;
+        };
+        ImageMatch: {
+            /** @example alpine@sha256:beefdbd8a1da6d2915566fde36db9db0b524eb737fc57cd1367effd16dc0d06d */
+            imageId: string;
+            /**
+             * @description are the package URLs of the matching components in the image.
+             * @example [
+             *       "pkg:apk/alpine/libssl3@3.0.8-r0"
+             *     ]
+             */
+            purls: string[];
+            /**
+             * @description is the highest severity of the vulnerability in the image, if a vulnerability was searched for.
+             * @example high
+             */
+            severity?: string;
+            /** @description are the containers currently running the image. */
+            workloads: components["schemas"]["Workload"][];
+        };
+        Workload: {
+            namespace: string;
+            pod: string;
+            container: string;
+            /**
+             * @description is the kind of the top-level controller of the pod, e.g. Deployment.
+             * @example Deployment
+             */
+            kind?: string;
+            /** @description is the name of the top-level controller of the pod. */
+            name?: string;
+        };
+        Scan: {
+            /** Format: int64 */
+            id: number;
+            /** @example alpine@sha256:beefdbd8a1da6d2915566fde36db9db0b524eb737fc57cd1367effd16dc0d06d */
+            imageId: string;
+            /** Format: date-time */
+            createdAt: string;
+            /** @example grype */
+            scannerName: string;
+            /** @example 0.83.0 */
+            scannerVersion: string;
+            /** @description is the version of the vulnerability database used by the scanner, if known. */
+            databaseVersion: string;
+            /** Format: double */
+            scanDurationSeconds: number;
+        };
+        Finding: {
+            /** @example CVE-2023-0464 */
+            vulnerabilityId: string;
+            aliases: string[];
+            /**
+             * @description is the package URL of the affected component.
+             * @example pkg:apk/alpine/libssl3@3.0.8-r0
+             */
+            purl: string;
+            /** @example high */
+            severity: string;
+            /**
+             * @description is the severity of the finding in the scan compared against, if it changed.
+             * @example medium
+             */
+            previousSeverity?: string;
+        };
+        ScanDiff: {
+            from?: components["schemas"]["Scan"];
+            to: components["schemas"]["Scan"];
+            introduced: components["schemas"]["Finding"][];
+            fixed: components["schemas"]["Finding"][];
+            severityChanged: components["schemas"]["Finding"][];
+        };
+        /** @description describes why a request failed, as defined by RFC 7807. Clients tell the errors apart by
+         *     their type, the title and the detail are meant for humans.
+         *      */
+        Problem: {
+            /**
+             * Format: uri
+             * @description identifies the kind of error.
+             * @example https://scanner.zoltankerezsi.xyz/problems/invalid-cyclonedx-bom
+             */
+            type: string;
+            /**
+             * @description is a short summary of the kind of error, which does not change between occurrences.
+             * @example Invalid CycloneDX BOM
+             */
+            title: string;
+            /**
+             * @description is the HTTP status code of the response.
+             * @example 400
+             */
+            status: number;
+            /** @description explains this occurrence of the error. */
+            detail?: string;
+            /**
+             * Format: uri
+             * @description is the path of the request which failed.
+             * @example /scan-results
+             */
+            instance?: string;
+        };
+    };
+    responses: {
+        /** @description A parameter or the body of the request is invalid. */
+        BadRequest: {
+            headers: {
+                [name: string]: unknown;
+            };
+            content: {
+                "application/problem+json": components["schemas"]["Problem"];
+            };
         };
+        /** @description The request is not authenticated. */
+        Unauthorized: {
+            headers: {
+                [name: string]: unknown;
+            };
+            content: {
+                "application/problem+json": components["schemas"]["Problem"];
+            };
+        };
+        /** @description The resource does not exist or is not visible to the caller. */
+        NotFound: {
+            headers: {
+                [name: string]: unknown;
+            };
+            content: {
+                "application/problem+json": components["schemas"]["Problem"];
+            };
+        };
+        /** @description The request failed on the server. */
+        InternalServerError: {
+            headers: {
+                [name: string]: unknown;
+            };
+            content: {
+                "application/problem+json": components["schemas"]["Problem"];
+            };
+        };
+        /** @description The database or the server is unavailable for the moment, the request can be retried after
+         *     the time in the Retry-After header.
+         *      */
+        ServiceUnavailable: {
+            headers: {
+                [name: string]: unknown;
+            };
+            content: {
+                "application/problem+json": components["schemas"]["Problem"];
+            };
+        };
+    };
+    parameters: {
+        /** @description Only Events of images running in one of the namespaces. */
+        EventNamespaces: string[];
+        /** @description Only Events of images pulled by digest from one of the repositories. */
+        EventRepositories: string[];
+        EventMinSeverity: components["schemas"]["Severity"];
+        /** @description Only Events of these types. */
+        EventTypes: components["schemas"]["EventType"][];
     };
-    responses: never;
-    parameters: never;
     requestBodies: never;
     headers: never;
     pathItems: never;
@@ -0,0 +1,409 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"github.com/kerezsiz42/scanner-operator2/internal/events"
+	"github.com/kerezsiz42/scanner-operator2/internal/storage"
+	"github.com/kerezsiz42/scanner-operator2/internal/tracing"
+)
+
+// Config is the configuration of the operator. It is loaded from the
+// defaults, the optional YAML file passed with --config, the environment
+// variables and the command line flags, each overriding the previous ones.
+type Config struct {
+	Database    database.Options `json:"database"`
+	ReportStore storage.Options  `json:"reportStore"`
+	EventBus    EventBus         `json:"eventBus"`
+	API         API              `json:"api"`
+	ScanJob     ScanJob          `json:"scanJob"`
+	Tracing     tracing.Options  `json:"tracing"`
+	// AutoMigrate applies the pending database migrations on startup.
+	AutoMigrate bool `json:"autoMigrate"`
+	// RescanAfter is the age of the ScanResults after which their images are
+	// scanned again. Images are not rescanned if 0.
+	RescanAfter Duration `json:"rescanAfter,omitempty"`
+	// MetricsMaxImages is the maximum number of images exported with an
+	// image label by scanner_image_vulnerabilities.
+	MetricsMaxImages int `json:"metricsMaxImages"`
+
+	file  string
+	flags []string
+}
+
+type EventBus struct {
+	Type         events.BusType `json:"type,omitempty"`
+	PollInterval Duration       `json:"pollInterval,omitempty"`
+}
+
+func (e EventBus) Options() events.Options {
+	return events.Options{
+		Type:         e.Type,
+		PollInterval: e.PollInterval.Duration,
+	}
+}
+
+type API struct {
+	BindAddress string `json:"bindAddress"`
+	// CertDir holds tls.crt and tls.key the API is served with. The API is
+	// served over HTTP if empty.
+	CertDir string `json:"certDir,omitempty"`
+	// CAName is the CA bundle in CertDir the scan Jobs verify the certificate
+	// of the API with, like the ca.crt of a cert-manager Secret.
+	CAName string `json:"caName,omitempty"`
+	// ClientCAName is the CA bundle in CertDir the client certificates are
+	// verified with. The frontend and the uploads of the scan Jobs are
+	// served without a client certificate.
+	ClientCAName string `json:"clientCAName,omitempty"`
+	// Auth authenticates the requests with TokenReviews and authorizes them
+	// with SubjectAccessReviews. It is off by default, as the embedded
+	// frontend sends no credentials and would be locked out of the API. With
+	// Auth the API is meant for the clients which can present a bearer token,
+	// e.g. behind an authenticating proxy serving the frontend.
+	Auth bool `json:"auth"`
+	// LeaderElection serves the API on the leader only.
+	LeaderElection bool `json:"leaderElection"`
+}
+
+// Port returns the port of BindAddress, which the scan Jobs upload to.
+func (a API) Port() (int, error) {
+	_, port, err := net.SplitHostPort(a.BindAddress)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(port)
+}
+
+type ScanJob struct {
+	// APIServiceHostname is the host the scan Jobs upload their reports to.
+	APIServiceHostname string `json:"apiServiceHostname"`
+	// ClusterRole is bound to the service account of the scan Jobs.
+	ClusterRole string `json:"clusterRole,omitempty"`
+	// UploadTokenKey signs the upload tokens. It has to be shared by the
+	// replicas, otherwise the tokens are only accepted by the one issuing
+	// them.
+	UploadTokenKey string `json:"uploadTokenKey,omitempty"`
+}
+
+// Duration is a time.Duration written as a string like "10m" in the YAML
+// file.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string like \"10m\": %w", err)
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	d.Duration = duration
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Duration.String())
+}
+
+// Default returns the configuration used where nothing else is set.
+func Default() *Config {
+	return &Config{
+		Database: database.Options{
+			Type: database.PostgreSQL,
+		},
+		ReportStore: storage.Options{
+			Type: storage.Database,
+		},
+		EventBus: EventBus{
+			Type:         events.Memory,
+			PollInterval: Duration{2 * time.Second},
+		},
+		API: API{
+			BindAddress: ":8000",
+			CAName:      "ca.crt",
+		},
+		Tracing: tracing.Options{
+			SampleRatio: 1,
+		},
+		AutoMigrate:      true,
+		MetricsMaxImages: 500,
+	}
+}
+
+// BindFlags registers the flags of the configuration and --config on fs.
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.file, "config", "",
+		"The YAML file the configuration is loaded from. The environment variables and the flags override it.")
+
+	bind := func(name string) string {
+		c.flags = append(c.flags, name)
+		return name
+	}
+
+	fs.StringVar((*string)(&c.Database.Type), bind("database-type"), string(c.Database.Type),
+		"The type of the database, one of postgres, mysql or sqlite.")
+	fs.StringVar(&c.Database.DSNFile, bind("dsn-file"), c.Database.DSNFile,
+		"The file the DSN of the database is read from, e.g. a mounted Secret. "+
+			"The new connections are opened with the DSN in the file when it changes.")
+	fs.StringVar((*string)(&c.ReportStore.Type), bind("report-store"), string(c.ReportStore.Type),
+		"The store of the reports, one of database, filesystem or s3.")
+	fs.StringVar((*string)(&c.EventBus.Type), bind("event-bus"), string(c.EventBus.Type),
+		"The bus the events are exchanged between the replicas with, one of memory, postgres or polling.")
+	fs.BoolVar(&c.AutoMigrate, bind("auto-migrate"), c.AutoMigrate,
+		"If set, pending database migrations are applied on startup. "+
+			"Use --auto-migrate=false when migrations are run separately with the migrate subcommand.")
+	fs.IntVar(&c.MetricsMaxImages, bind("metrics-max-images"), c.MetricsMaxImages,
+		"The maximum number of images exported with an image label by scanner_image_vulnerabilities.")
+	fs.BoolVar(&c.API.Auth, bind("api-auth"), c.API.Auth,
+		"If set, the requests of the Scanner API are authenticated with TokenReviews and authorized with "+
+			"SubjectAccessReviews on the scanresults resource of the scanner.zoltankerezsi.xyz group. "+
+			"The embedded frontend sends no bearer token, so it needs an authenticating proxy in front of it then. "+
+			"Requires --scan-job-cluster-role, which allows the scan Jobs to upload their reports.")
+	fs.StringVar(&c.API.BindAddress, bind("api-bind-address"), c.API.BindAddress,
+		"The address the Scanner API binds to.")
+	fs.StringVar(&c.API.CertDir, bind("api-cert-dir"), c.API.CertDir,
+		"The directory holding tls.crt and tls.key the Scanner API is served with. "+
+			"The Scanner API is served over HTTP if empty. The certificate is reloaded when rotated.")
+	fs.StringVar(&c.API.CAName, bind("api-ca-name"), c.API.CAName,
+		"The CA bundle in --api-cert-dir the scan Jobs verify the certificate of the Scanner API with.")
+	fs.StringVar(&c.API.ClientCAName, bind("api-client-ca-name"), c.API.ClientCAName,
+		"The CA bundle in --api-cert-dir the client certificates are verified with. "+
+			"If set, the clients of the Scanner API are required to present a certificate, "+
+			"except for loading the frontend and the uploads of the scan Jobs.")
+	fs.BoolVar(&c.API.LeaderElection, bind("api-leader-election"), c.API.LeaderElection,
+		"If set, the Scanner API is only served by the leader. Otherwise every replica serves it, "+
+			"which needs an event bus other than memory with --leader-elect to deliver the events of the leader.")
+	fs.StringVar(&c.ScanJob.APIServiceHostname, bind("api-service-hostname"), c.ScanJob.APIServiceHostname,
+		"The host the scan Jobs upload their reports to.")
+	fs.StringVar(&c.ScanJob.ClusterRole, bind("scan-job-cluster-role"), c.ScanJob.ClusterRole,
+		"The ClusterRole bound to the service account of the scan Jobs.")
+	fs.DurationVar(&c.RescanAfter.Duration, bind("rescan-after"), c.RescanAfter.Duration,
+		"The age of the ScanResults after which their images are scanned again, e.g. with an updated "+
+			"vulnerability database. Images are not rescanned if 0.")
+	fs.StringVar(&c.Tracing.Endpoint, bind("tracing-endpoint"), c.Tracing.Endpoint,
+		"The host:port of the OTLP gRPC receiver the spans are exported to. Tracing is disabled if empty.")
+}
+
+// Load applies the YAML file and the environment variables once fs was
+// parsed, keeping the values of the flags set on the command line.
+func (c *Config) Load(fs *flag.FlagSet) error {
+	set := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		for _, name := range c.flags {
+			if f.Name == name {
+				set[f.Name] = f.Value.String()
+			}
+		}
+	})
+
+	if c.file != "" {
+		data, err := os.ReadFile(c.file)
+		if err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		if err := yaml.UnmarshalStrict(data, c); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", c.file, err)
+		}
+	}
+
+	if err := c.applyEnv(); err != nil {
+		return err
+	}
+
+	for name, value := range set {
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for flag --%s: %w", value, name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) applyEnv() error {
+	str := func(name string, value *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*value = v
+		}
+	}
+
+	boolean := func(name string, value *bool) error {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid value %q for environment variable %s: expected true or false", v, name)
+			}
+
+			*value = b
+		}
+
+		return nil
+	}
+
+	duration := func(name string, value *Duration) error {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid value %q for environment variable %s: expected a duration like \"10m\"", v, name)
+			}
+
+			value.Duration = d
+		}
+
+		return nil
+	}
+
+	ratio := func(name string, value *float64) error {
+		if v, ok := os.LookupEnv(name); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("invalid value %q for environment variable %s: expected a number between 0 and 1", v, name)
+			}
+
+			*value = f
+		}
+
+		return nil
+	}
+
+	str("DATABASE_TYPE", (*string)(&c.Database.Type))
+	str("DSN", &c.Database.DSN)
+	str("DSN_FILE", &c.Database.DSNFile)
+	str("REPORT_STORE", (*string)(&c.ReportStore.Type))
+	str("REPORT_STORE_PATH", &c.ReportStore.Path)
+	str("S3_ENDPOINT", &c.ReportStore.S3.Endpoint)
+	str("S3_BUCKET", &c.ReportStore.S3.Bucket)
+	str("S3_REGION", &c.ReportStore.S3.Region)
+	str("S3_ACCESS_KEY_ID", &c.ReportStore.S3.AccessKeyID)
+	str("S3_SECRET_ACCESS_KEY", &c.ReportStore.S3.SecretAccessKey)
+	str("EVENT_BUS", (*string)(&c.EventBus.Type))
+	str("API_SERVICE_HOSTNAME", &c.ScanJob.APIServiceHostname)
+	str("SCAN_JOB_CLUSTER_ROLE", &c.ScanJob.ClusterRole)
+	str("UPLOAD_TOKEN_KEY", &c.ScanJob.UploadTokenKey)
+	str("TRACING_ENDPOINT", &c.Tracing.Endpoint)
+
+	return errors.Join(
+		boolean("S3_INSECURE", &c.ReportStore.S3.Insecure),
+		duration("EVENT_BUS_POLL_INTERVAL", &c.EventBus.PollInterval),
+		boolean("TRACING_INSECURE", &c.Tracing.Insecure),
+		ratio("TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio),
+	)
+}
+
+// ValidateStorage checks the settings of the database and the report store,
+// which are all the migrate subcommand needs.
+func (c *Config) ValidateStorage() error {
+	errs := []error{}
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	switch c.Database.Type {
+	case database.PostgreSQL, database.MySQL, database.SQLite:
+	case "":
+		invalid("database.type is required (DATABASE_TYPE, --database-type)")
+	default:
+		invalid("database.type %q is not supported, expected postgres, mysql or sqlite", c.Database.Type)
+	}
+
+	if c.Database.DSN == "" && c.Database.DSNFile == "" {
+		invalid("database.dsn (DSN) or database.dsnFile (DSN_FILE, --dsn-file) is required")
+	} else if c.Database.DSN != "" && c.Database.DSNFile != "" {
+		invalid("database.dsn and database.dsnFile are mutually exclusive")
+	}
+
+	switch c.ReportStore.Type {
+	case "", storage.Database:
+	case storage.Filesystem:
+		if c.ReportStore.Path == "" {
+			invalid("reportStore.path (REPORT_STORE_PATH) is required by the filesystem report store")
+		}
+	case storage.S3:
+		if c.ReportStore.S3.Endpoint == "" {
+			invalid("reportStore.s3.endpoint (S3_ENDPOINT) is required by the s3 report store")
+		}
+
+		if c.ReportStore.S3.Bucket == "" {
+			invalid("reportStore.s3.bucket (S3_BUCKET) is required by the s3 report store")
+		}
+	default:
+		invalid("reportStore.type %q is not supported, expected database, filesystem or s3", c.ReportStore.Type)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks the whole configuration and reports every invalid setting.
+func (c *Config) Validate() error {
+	errs := []error{c.ValidateStorage()}
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	switch c.EventBus.Type {
+	case "", events.Memory, events.Polling:
+	case events.Postgres:
+		if c.Database.Type != database.PostgreSQL {
+			invalid("eventBus.type %q requires a postgres database", c.EventBus.Type)
+		}
+	default:
+		invalid("eventBus.type %q is not supported, expected memory, postgres or polling", c.EventBus.Type)
+	}
+
+	if c.EventBus.PollInterval.Duration < 0 {
+		invalid("eventBus.pollInterval must not be negative")
+	}
+
+	if c.API.BindAddress == "" {
+		invalid("api.bindAddress is required (--api-bind-address)")
+	} else if _, err := c.API.Port(); err != nil {
+		invalid("api.bindAddress %q must be a host:port: %v", c.API.BindAddress, err)
+	}
+
+	if c.API.CertDir != "" && c.API.CAName == "" {
+		invalid("api.certDir requires api.caName (--api-ca-name), which the scan Jobs verify the Scanner API with")
+	}
+
+	if c.API.ClientCAName != "" && c.API.CertDir == "" {
+		invalid("api.clientCAName requires api.certDir (--api-cert-dir)")
+	}
+
+	if c.API.Auth && c.ScanJob.ClusterRole == "" {
+		invalid("api.auth requires scanJob.clusterRole (SCAN_JOB_CLUSTER_ROLE, --scan-job-cluster-role), " +
+			"otherwise the scan Jobs are not allowed to upload their reports")
+	}
+
+	if c.ScanJob.APIServiceHostname == "" {
+		invalid("scanJob.apiServiceHostname is required (API_SERVICE_HOSTNAME, --api-service-hostname)")
+	}
+
+	if c.RescanAfter.Duration < 0 {
+		invalid("rescanAfter must not be negative")
+	}
+
+	if c.MetricsMaxImages < 0 {
+		invalid("metricsMaxImages must not be negative")
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		invalid("tracing.sampleRatio must be between 0 and 1")
+	}
+
+	return errors.Join(errs...)
+}
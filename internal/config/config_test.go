@@ -0,0 +1,153 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"github.com/kerezsiz42/scanner-operator2/internal/events"
+	"github.com/kerezsiz42/scanner-operator2/internal/storage"
+)
+
+func load(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	cfg := Default()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.BindFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return cfg, cfg.Load(fs)
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, `
+database:
+  type: mysql
+  dsn: from-file
+eventBus:
+  type: polling
+  pollInterval: 5s
+api:
+  bindAddress: ":9000"
+  auth: false
+scanJob:
+  apiServiceHostname: file-host
+rescanAfter: 24h
+`)
+	t.Setenv("DSN", "from-env")
+	t.Setenv("API_SERVICE_HOSTNAME", "env-host")
+	t.Setenv("SCAN_JOB_CLUSTER_ROLE", "scanner-scanresult-uploader-role")
+
+	cfg, err := load(t, "--config", path, "--api-service-hostname", "flag-host", "--api-auth=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Database.Type != database.MySQL {
+		t.Errorf("expected database type of the file, got %q", cfg.Database.Type)
+	}
+
+	if cfg.Database.DSN != "from-env" {
+		t.Errorf("expected DSN of the environment, got %q", cfg.Database.DSN)
+	}
+
+	if cfg.ScanJob.APIServiceHostname != "flag-host" {
+		t.Errorf("expected hostname of the flag, got %q", cfg.ScanJob.APIServiceHostname)
+	}
+
+	if !cfg.API.Auth || cfg.API.BindAddress != ":9000" {
+		t.Errorf("unexpected API configuration: %+v", cfg.API)
+	}
+
+	if cfg.EventBus.Options() != (events.Options{Type: events.Polling, PollInterval: 5 * time.Second}) {
+		t.Errorf("unexpected event bus options: %+v", cfg.EventBus.Options())
+	}
+
+	if cfg.RescanAfter.Duration != 24*time.Hour {
+		t.Errorf("expected rescanAfter of the file, got %s", cfg.RescanAfter)
+	}
+
+	if cfg.ReportStore.Type != storage.Database || !cfg.AutoMigrate {
+		t.Errorf("expected defaults to be kept, got %+v", cfg)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if _, err := load(t, "--config", writeFile(t, "database:\n  typo: postgres\n")); err == nil ||
+		!strings.Contains(err.Error(), "typo") {
+		t.Errorf("expected unknown field error, got %v", err)
+	}
+
+	if _, err := load(t, "--config", writeFile(t, "rescanAfter: 1 day\n")); err == nil {
+		t.Error("expected invalid duration error")
+	}
+
+	if _, err := load(t, "--config", filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected missing file error")
+	}
+
+	t.Setenv("S3_INSECURE", "maybe")
+	if _, err := load(t); err == nil || !strings.Contains(err.Error(), "S3_INSECURE") {
+		t.Errorf("expected invalid environment variable error, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Type = "oracle"
+	cfg.ReportStore.Type = storage.S3
+	cfg.EventBus.Type = events.Postgres
+	cfg.API.ClientCAName = "ca.crt"
+	cfg.API.Auth = true
+	cfg.API.BindAddress = "8000"
+	cfg.Tracing.SampleRatio = 1.5
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+
+	for _, expected := range []string{
+		`database.type "oracle" is not supported`,
+		"database.dsn (DSN) or database.dsnFile",
+		"reportStore.s3.endpoint",
+		"reportStore.s3.bucket",
+		`eventBus.type "postgres" requires a postgres database`,
+		`api.bindAddress "8000" must be a host:port`,
+		"api.clientCAName requires api.certDir",
+		"api.auth requires scanJob.clusterRole",
+		"scanJob.apiServiceHostname is required",
+		"tracing.sampleRatio must be between 0 and 1",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %q", expected, err)
+		}
+	}
+
+	cfg = Default()
+	cfg.Database.DSN = "dsn"
+	cfg.Database.DSNFile = "/etc/scanner/dsn"
+	if err := cfg.ValidateStorage(); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("expected mutually exclusive error, got %v", err)
+	}
+}
@@ -19,10 +19,17 @@ package controller
 import (
 	"context"
 	"slices"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -32,28 +39,57 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	scannerv1 "github.com/kerezsiz42/scanner-operator2/api/v1"
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"github.com/kerezsiz42/scanner-operator2/internal/events"
+	"github.com/kerezsiz42/scanner-operator2/internal/metrics"
 	"github.com/kerezsiz42/scanner-operator2/internal/service"
 )
 
+var tracer = otel.Tracer("github.com/kerezsiz42/scanner-operator2/internal/controller")
+
 // ScannerReconciler reconciles a Scanner object
 type ScannerReconciler struct {
 	client.Client
 	Scheme           *runtime.Scheme
 	ScanService      service.ScanServiceInterface
 	JobObjectService service.JobObjectServiceInterface
+	Events           events.Publisher
+	// ScanJobClusterRole is bound to the service account of the scan Jobs in
+	// the namespaces they run in, allowing them to upload their reports when
+	// the API is authorized. The service account is created without a
+	// binding if empty.
+	ScanJobClusterRole string
+	// Database is checked before reconciling, so that the Scanners are
+	// requeued with backoff while it is unavailable. It is not checked if nil.
+	Database database.Availability
+
+	// failedJobs holds the UIDs of the failed Jobs counted already.
+	failedJobs sync.Map
+	// recordedImages holds the set of the image IDs recorded already for each
+	// namespace. Only the images running at the last reconciliation are kept,
+	// and the namespace is dropped when its Scanner is deleted.
+	recordedImages sync.Map
 }
 
 // +kubebuilder:rbac:groups=scanner.zoltankerezsi.xyz,resources=scanners,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=scanner.zoltankerezsi.xyz,resources=scanners/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=scanner.zoltankerezsi.xyz,resources=scanners/finalizers,verbs=update
 // +kubebuilder:rbac:groups="",resources=pods,verbs=list;watch
-// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=list;watch;create
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=create
+// +kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=create
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=create
+// +kubebuilder:rbac:groups=scanner.zoltankerezsi.xyz,resources=scanresults,verbs=update
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -65,23 +101,47 @@ type ScannerReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.18.4/pkg/reconcile
 func (r *ScannerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := tracer.Start(ctx, "ScannerReconciler.Reconcile", trace.WithAttributes(
+		attribute.String("k8s.namespace.name", req.Namespace),
+		attribute.String("scanner.name", req.Name),
+	))
+	defer span.End()
+
+	result, err := r.reconcile(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return result, err
+}
+
+func (r *ScannerReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	reconcilerLog := log.FromContext(ctx)
 
 	scanner := &scannerv1.Scanner{}
 	if err := r.Get(ctx, req.NamespacedName, scanner); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.recordedImages.Delete(req.Namespace)
+			// The gauge of a deleted Scanner would keep its last value.
+			metrics.ImagesPending.DeleteLabelValues(req.Namespace)
+		}
+
 		reconcilerLog.Error(err, "unable to list scanner resources")
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	scanResults, err := r.ScanService.ListScanResults()
-	if err != nil {
-		reconcilerLog.Error(err, "failed to list scan results")
-		return ctrl.Result{}, r.nextStatusCondition(ctx, scanner, scannerv1.Failed)
+	// The rate limiter of the controller backs off exponentially while the
+	// Scanner is requeued.
+	if r.Database != nil && !r.Database.Available() {
+		reconcilerLog.Info("database unavailable, requeueing")
+		return ctrl.Result{Requeue: true}, nil
 	}
 
-	scannedImageIDs := []string{}
-	for _, scanResult := range scanResults {
-		scannedImageIDs = append(scannedImageIDs, scanResult.ImageID)
+	scannedImageIDs, err := r.ScanService.ScannedImageIDs(ctx)
+	if err != nil {
+		reconcilerLog.Error(err, "failed to list scanned images")
+		return ctrl.Result{Requeue: true}, r.nextStatusCondition(ctx, scanner, scannerv1.Failed)
 	}
 
 	labelRequirement, err := labels.NewRequirement(scanner.Spec.IgnoreLabel, selection.NotEquals, []string{"true"})
@@ -100,17 +160,35 @@ func (r *ScannerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	}
 
 	imageID := ""
-OuterLoop:
+	pendingImageIDs := map[string]bool{}
+	runningImageIDs := []string{}
 	for _, pod := range podList.Items {
 		// TODO: Handle init containers as well
 		for _, containerStatus := range pod.Status.ContainerStatuses {
-			if !slices.Contains(scannedImageIDs, containerStatus.ImageID) {
+			if containerStatus.ImageID != "" {
+				runningImageIDs = append(runningImageIDs, containerStatus.ImageID)
+			}
+
+			if containerStatus.ImageID == "" || scannedImageIDs[containerStatus.ImageID] {
+				continue
+			}
+
+			if imageID == "" {
 				imageID = containerStatus.ImageID
-				break OuterLoop
 			}
+
+			pendingImageIDs[containerStatus.ImageID] = true
 		}
 	}
 
+	metrics.ImagesPending.WithLabelValues(scanner.Namespace).Set(float64(len(pendingImageIDs)))
+
+	// The namespaces of the images decide who can see their ScanResults, so
+	// a failure is not fatal to scanning.
+	if err := r.recordImageNamespaces(ctx, scanner.Namespace, runningImageIDs); err != nil {
+		reconcilerLog.Error(err, "failed to record namespaces of images")
+	}
+
 	if imageID == "" {
 		reconcilerLog.Info("all images scanned, successfully reconciled")
 		return ctrl.Result{RequeueAfter: 10 * time.Second}, r.nextStatusCondition(ctx, scanner, scannerv1.Reconciled)
@@ -124,6 +202,7 @@ OuterLoop:
 		return ctrl.Result{}, r.nextStatusCondition(ctx, scanner, scannerv1.Failed)
 	}
 
+	r.countFailedJobs(scanner.Namespace, jobList.Items)
 	for _, job := range jobList.Items {
 		if job.Status.Succeeded == 0 {
 			reconcilerLog.Info("job is still in progress")
@@ -131,12 +210,30 @@ OuterLoop:
 		}
 	}
 
-	nextJob, err := r.JobObjectService.Create(imageID, scanner.Namespace)
+	triggers := []service.ImageUsage{}
+	for _, pod := range podList.Items {
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if containerStatus.ImageID == imageID {
+				triggers = append(triggers, service.ImageUsage{
+					Namespace: pod.Namespace,
+					Pod:       pod.Name,
+					Container: containerStatus.Name,
+				})
+			}
+		}
+	}
+
+	nextJob, uploadTokenSecret, err := r.JobObjectService.Create(ctx, imageID, scanner.Namespace, triggers)
 	if err != nil {
 		reconcilerLog.Error(err, "failed to create job from template")
 		return ctrl.Result{}, r.nextStatusCondition(ctx, scanner, scannerv1.Failed)
 	}
 
+	if err := r.ensureScanJobServiceAccount(ctx, scanner); err != nil {
+		reconcilerLog.Error(err, "failed to create service account of the job")
+		return ctrl.Result{}, r.nextStatusCondition(ctx, scanner, scannerv1.Failed)
+	}
+
 	if err := ctrl.SetControllerReference(scanner, nextJob, r.Scheme); err != nil {
 		reconcilerLog.Error(err, "failed to set controller reference on job")
 		return ctrl.Result{}, r.nextStatusCondition(ctx, scanner, scannerv1.Failed)
@@ -147,10 +244,153 @@ OuterLoop:
 		return ctrl.Result{}, r.nextStatusCondition(ctx, scanner, scannerv1.Failed)
 	}
 
+	// The Secret is owned by the Job, so it is deleted along with it. The pod
+	// of the Job waits for the Secret to be created.
+	if err := r.createUploadTokenSecret(ctx, nextJob, uploadTokenSecret); err != nil {
+		reconcilerLog.Error(err, "failed to create upload token secret of the job")
+		if err := r.Delete(ctx, nextJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+			reconcilerLog.Error(err, "failed to delete job without upload token")
+		}
+
+		return ctrl.Result{}, r.nextStatusCondition(ctx, scanner, scannerv1.Failed)
+	}
+
 	reconcilerLog.Info("new job created")
+	r.publish(events.Event{Type: events.ScanStarted, ImageID: imageID, Namespaces: []string{scanner.Namespace}})
 	return ctrl.Result{}, r.nextStatusCondition(ctx, scanner, scannerv1.Scanning)
 }
 
+func (r *ScannerReconciler) createUploadTokenSecret(ctx context.Context, job *batchv1.Job, secret *corev1.Secret) error {
+	if err := controllerutil.SetOwnerReference(job, secret, r.Scheme); err != nil {
+		return err
+	}
+
+	return r.Create(ctx, secret)
+}
+
+// recordImageNamespaces records the images running in the namespace, skipping
+// the ones recorded by this reconciler already.
+func (r *ScannerReconciler) recordImageNamespaces(ctx context.Context, namespace string, imageIDs []string) error {
+	recorded := map[string]struct{}{}
+	if value, ok := r.recordedImages.Load(namespace); ok {
+		recorded = value.(map[string]struct{})
+	}
+
+	unrecorded := []string{}
+	for _, imageID := range imageIDs {
+		if _, ok := recorded[imageID]; !ok && !slices.Contains(unrecorded, imageID) {
+			unrecorded = append(unrecorded, imageID)
+		}
+	}
+
+	if len(unrecorded) > 0 {
+		if err := r.ScanService.RecordImageNamespaces(ctx, namespace, unrecorded); err != nil {
+			return err
+		}
+	}
+
+	// The set is replaced instead of modified, as it may be read by the
+	// reconciliation of another Scanner in the namespace.
+	running := make(map[string]struct{}, len(imageIDs))
+	for _, imageID := range imageIDs {
+		running[imageID] = struct{}{}
+	}
+
+	r.recordedImages.Store(namespace, running)
+	return nil
+}
+
+// ensureScanJobServiceAccount creates the service account the scan Jobs run
+// with in the namespace of the scanner, bound to ScanJobClusterRole if set.
+func (r *ScannerReconciler) ensureScanJobServiceAccount(ctx context.Context, scanner *scannerv1.Scanner) error {
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      service.ScanJobServiceAccount,
+			Namespace: scanner.Namespace,
+		},
+	}
+
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      service.ScanJobServiceAccount,
+			Namespace: scanner.Namespace,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     r.ScanJobClusterRole,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      service.ScanJobServiceAccount,
+			Namespace: scanner.Namespace,
+		}},
+	}
+
+	objects := []client.Object{serviceAccount}
+	if r.ScanJobClusterRole != "" {
+		objects = append(objects, roleBinding)
+	}
+
+	for _, object := range objects {
+		if err := ctrl.SetControllerReference(scanner, object, r.Scheme); err != nil {
+			return err
+		}
+
+		if err := r.Create(ctx, object); err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *ScannerReconciler) publish(event events.Event) {
+	if r.Events != nil {
+		r.Events.Publish(event)
+	}
+}
+
+// countFailedJobs counts every failed Job once in the scan failures metric and
+// publishes a ScanFailed event for it.
+// Jobs which no longer exist are forgotten.
+func (r *ScannerReconciler) countFailedJobs(namespace string, jobs []batchv1.Job) {
+	existing := map[types.UID]bool{}
+	for _, job := range jobs {
+		existing[job.UID] = true
+		if !isJobFailed(&job) {
+			continue
+		}
+
+		if _, counted := r.failedJobs.LoadOrStore(job.UID, namespace); !counted {
+			metrics.ScanFailuresTotal.WithLabelValues(metrics.JobFailed).Inc()
+			r.publish(events.Event{
+				Type:       events.ScanFailed,
+				ImageID:    job.Annotations[service.ImageIDAnnotation],
+				Namespaces: []string{namespace},
+			})
+		}
+	}
+
+	r.failedJobs.Range(func(uid, jobNamespace any) bool {
+		if jobNamespace == namespace && !existing[uid.(types.UID)] {
+			r.failedJobs.Delete(uid)
+		}
+
+		return true
+	})
+}
+
+func isJobFailed(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (r *ScannerReconciler) mapPodsToRequests(ctx context.Context, pod client.Object) []reconcile.Request {
 	scannerList := &scannerv1.ScannerList{}
 	if err := r.List(ctx, scannerList, &client.ListOptions{Namespace: pod.GetNamespace()}); err != nil {
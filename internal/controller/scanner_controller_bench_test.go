@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	scannerv1 "github.com/kerezsiz42/scanner-operator2/api/v1"
+	"github.com/kerezsiz42/scanner-operator2/internal/service"
+)
+
+// scannedImageService serves the scanned images from memory, so that the
+// benchmark measures the reconciler and not the database.
+type scannedImageService struct {
+	service.ScanServiceInterface
+	imageIDs map[string]bool
+}
+
+func (s *scannedImageService) ScannedImageIDs(_ context.Context) (map[string]bool, error) {
+	return s.imageIDs, nil
+}
+
+func (s *scannedImageService) RecordImageNamespaces(_ context.Context, namespace string, imageIds []string) error {
+	return nil
+}
+
+func benchmarkImageID(i int) string {
+	return fmt.Sprintf("docker.io/library/image@sha256:%064d", i)
+}
+
+// BenchmarkReconcile reconciles a namespace in which every image is already
+// scanned, which is the common case, with an increasing number of results.
+func BenchmarkReconcile(b *testing.B) {
+	const pods = 500
+	const containersPerPod = 3
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		b.Fatal(err)
+	}
+
+	if err := scannerv1.AddToScheme(scheme); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, results := range []int{1000, 10000, 50000} {
+		b.Run(fmt.Sprintf("results=%d", results), func(b *testing.B) {
+			scanner := &scannerv1.Scanner{
+				ObjectMeta: metav1.ObjectMeta{Name: "scanner", Namespace: "default"},
+				Spec:       scannerv1.ScannerSpec{IgnoreLabel: "scanner.zoltankerezsi.xyz/ignore"},
+			}
+
+			objects := []client.Object{scanner}
+			for i := range pods {
+				pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pod-%d", i), Namespace: "default"}}
+				for j := range containersPerPod {
+					pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+						Name:    fmt.Sprintf("container-%d", j),
+						ImageID: benchmarkImageID(results - 1 - (i*containersPerPod+j)%results),
+					})
+				}
+
+				objects = append(objects, pod)
+			}
+
+			imageIDs := make(map[string]bool, results)
+			for i := range results {
+				imageIDs[benchmarkImageID(i)] = true
+			}
+
+			r := &ScannerReconciler{
+				Client: fake.NewClientBuilder().
+					WithScheme(scheme).
+					WithObjects(objects...).
+					WithStatusSubresource(&scannerv1.Scanner{}).
+					Build(),
+				Scheme:      scheme,
+				ScanService: &scannedImageService{imageIDs: imageIDs},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "scanner", Namespace: "default"}}
+			ctx := context.Background()
+			b.ResetTimer()
+			for range b.N {
+				if _, err := r.Reconcile(ctx, req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
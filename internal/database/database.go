@@ -1,9 +1,12 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
-	"os"
+	"time"
 
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
@@ -18,6 +21,21 @@ const (
 	SQLite     DatabaseType = "sqlite"
 )
 
+// Options selects the database the operator connects to.
+type Options struct {
+	Type DatabaseType `json:"type"`
+	DSN  string       `json:"dsn,omitempty"`
+	// DSNFile is read instead of DSN, e.g. from a mounted Secret. The file is
+	// read again when it changes, so the new connections are opened with the
+	// rotated credentials.
+	DSNFile string `json:"dsnFile,omitempty"`
+}
+
+// connMaxLifetime closes the connections opened with a DSN read from a file
+// in time, so that the connections opened with rotated credentials replace
+// them.
+const connMaxLifetime = 5 * time.Minute
+
 func GetDialector(databaseType string, dsn string) (gorm.Dialector, error) {
 	switch databaseType {
 	case string(PostgreSQL):
@@ -31,23 +49,63 @@ func GetDialector(databaseType string, dsn string) (gorm.Dialector, error) {
 	}
 }
 
-func GetDatabase() (*gorm.DB, error) {
-	databaseType := os.Getenv("DATABASE_TYPE")
-	dsn := os.Getenv("DSN")
-
-	dialector, err := GetDialector(databaseType, dsn)
+// GetDatabase connects to the database. The failed and slow queries are
+// logged with the logger of their context, or with the given one.
+func GetDatabase(options Options, logger logr.Logger) (*gorm.DB, error) {
+	dialector, err := getDialector(options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get dialector: %w", err)
 	}
 
-	db, err := gorm.Open(dialector, &gorm.Config{})
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: NewLogger(logger)})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	if err := db.AutoMigrate(&ScanResult{}); err != nil {
-		return nil, fmt.Errorf("failed to automigrate database: %w", err)
+	if err := db.Use(newTracingPlugin(otel.GetTracerProvider())); err != nil {
+		return nil, fmt.Errorf("failed to register tracing plugin: %w", err)
+	}
+
+	if options.DSNFile != "" {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get connection pool: %w", err)
+		}
+
+		sqlDB.SetConnMaxLifetime(connMaxLifetime)
 	}
 
 	return db, nil
 }
+
+func getDialector(options Options) (gorm.Dialector, error) {
+	if options.DSNFile == "" {
+		return GetDialector(string(options.Type), options.DSN)
+	}
+
+	switch options.Type {
+	case PostgreSQL:
+		connector, err := newDSNFileConnector(options.DSNFile, newPostgresConnector)
+		if err != nil {
+			return nil, err
+		}
+
+		return postgres.New(postgres.Config{Conn: sql.OpenDB(connector)}), nil
+	case MySQL:
+		connector, err := newDSNFileConnector(options.DSNFile, newMySQLConnector)
+		if err != nil {
+			return nil, err
+		}
+
+		return mysql.New(mysql.Config{Conn: sql.OpenDB(connector)}), nil
+	default:
+		// The DSN of SQLite is the path of the database, so there are no
+		// credentials to rotate.
+		dsn, err := readDSNFile(options.DSNFile)
+		if err != nil {
+			return nil, err
+		}
+
+		return GetDialector(string(options.Type), dsn)
+	}
+}
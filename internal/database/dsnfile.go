@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// dsnFileConnector opens the connections with the DSN read from a file. The
+// file is read again when its modification time changes, e.g. when the
+// kubelet updates a mounted Secret, so that the connections opened after a
+// rotation use the new credentials.
+type dsnFileConnector struct {
+	path string
+	open func(dsn string) (driver.Connector, error)
+
+	mu        sync.Mutex
+	modTime   time.Time
+	connector driver.Connector
+}
+
+func newDSNFileConnector(path string, open func(dsn string) (driver.Connector, error)) (*dsnFileConnector, error) {
+	c := &dsnFileConnector{
+		path: path,
+		open: open,
+	}
+
+	if _, err := c.current(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *dsnFileConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	connector, err := c.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return connector.Connect(ctx)
+}
+
+func (c *dsnFileConnector) Driver() driver.Driver {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.connector.Driver()
+}
+
+// current returns the connector of the DSN in the file. The previous
+// connector is kept if the file cannot be read, e.g. while it is replaced.
+func (c *dsnFileConnector) current() (driver.Connector, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info, err := os.Stat(c.path)
+	if err != nil {
+		if c.connector != nil {
+			return c.connector, nil
+		}
+
+		return nil, fmt.Errorf("failed to read DSN file: %w", err)
+	}
+
+	if c.connector != nil && info.ModTime().Equal(c.modTime) {
+		return c.connector, nil
+	}
+
+	dsn, err := readDSNFile(c.path)
+	if err != nil {
+		if c.connector != nil {
+			return c.connector, nil
+		}
+
+		return nil, err
+	}
+
+	connector, err := c.open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN in %s: %w", c.path, err)
+	}
+
+	c.connector = connector
+	c.modTime = info.ModTime()
+	return connector, nil
+}
+
+func readDSNFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read DSN file: %w", err)
+	}
+
+	dsn := strings.TrimSpace(string(data))
+	if dsn == "" {
+		return "", fmt.Errorf("DSN file %s is empty", path)
+	}
+
+	return dsn, nil
+}
+
+func newPostgresConnector(dsn string) (driver.Connector, error) {
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return stdlib.GetConnector(*config), nil
+}
+
+func newMySQLConnector(dsn string) (driver.Connector, error) {
+	config, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return mysqldriver.NewConnector(config)
+}
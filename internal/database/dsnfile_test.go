@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fakeConnector struct {
+	dsn string
+}
+
+func (f *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, nil
+}
+
+func (f *fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+func TestDSNFileConnector(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dsn")
+	write := func(dsn string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(dsn+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opened := 0
+	open := func(dsn string) (driver.Connector, error) {
+		opened++
+		return &fakeConnector{dsn: dsn}, nil
+	}
+
+	now := time.Now()
+	write("user=a", now)
+	c, err := newDSNFileConnector(path, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dsn := func() string {
+		connector, err := c.current()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return connector.(*fakeConnector).dsn
+	}
+
+	if dsn() != "user=a" || opened != 1 {
+		t.Errorf("expected the DSN to be read once, got %q after %d reads", dsn(), opened)
+	}
+
+	write("user=b", now.Add(time.Minute))
+	if dsn() != "user=b" || opened != 2 {
+		t.Errorf("expected the rotated DSN, got %q after %d reads", dsn(), opened)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if dsn() != "user=b" {
+		t.Errorf("expected the previous DSN while the file is missing, got %q", dsn())
+	}
+}
+
+func TestDSNFileConnectorErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := newDSNFileConnector(filepath.Join(dir, "missing"), newPostgresConnector); err == nil {
+		t.Error("expected missing file error")
+	}
+
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newDSNFileConnector(empty, newPostgresConnector); err == nil {
+		t.Error("expected empty file error")
+	}
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gorm.io/gorm"
+)
+
+var Unavailable = errors.New("database unavailable")
+
+// Availability reports whether the database can serve the queries of the
+// operator, as of the latest check.
+type Availability interface {
+	Available() bool
+}
+
+// HealthChecker pings the database and verifies that its schema is migrated
+// to the version the operator expects. The result of the latest check is
+// kept, so that the API and the reconciler can back off while the database is
+// unavailable without waiting for a query to time out.
+type HealthChecker struct {
+	db        *gorm.DB
+	timeout   time.Duration
+	latest    int
+	logger    logr.Logger
+	available atomic.Bool
+}
+
+func NewHealthChecker(db *gorm.DB, timeout time.Duration, logger logr.Logger) (*HealthChecker, error) {
+	latest, err := LatestSchemaVersion(DatabaseType(db.Dialector.Name()))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &HealthChecker{
+		db:      db,
+		timeout: timeout,
+		latest:  latest,
+		logger:  logger,
+	}
+	c.available.Store(true)
+
+	return c, nil
+}
+
+func (c *HealthChecker) Available() bool {
+	return c.available.Load()
+}
+
+// Check pings the database within the timeout and compares its schema version
+// to the latest migration. A change of the availability is logged.
+func (c *HealthChecker) Check(ctx context.Context) error {
+	err := c.check(ctx)
+	if c.available.Swap(err == nil) != (err == nil) {
+		if err != nil {
+			c.logger.Error(err, "database became unavailable")
+		} else {
+			c.logger.Info("database became available")
+		}
+	}
+
+	return err
+}
+
+func (c *HealthChecker) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return fmt.Errorf("%w: %w", Unavailable, err)
+	}
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("%w: %w", Unavailable, err)
+	}
+
+	current, err := CurrentSchemaVersion(c.db.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("%w: %w", Unavailable, err)
+	}
+
+	if current < c.latest {
+		return fmt.Errorf("%w: schema version %d is behind %d, run the migrations", Unavailable, current, c.latest)
+	}
+
+	return nil
+}
+
+// ReadyzCheck is the readiness check of the manager.
+func (c *HealthChecker) ReadyzCheck(req *http.Request) error {
+	return c.Check(req.Context())
+}
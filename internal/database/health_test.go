@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+func TestHealthChecker(t *testing.T) {
+	db := newTestDatabase(t)
+	c, err := NewHealthChecker(db, time.Second, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Check(context.Background()); !errors.Is(err, Unavailable) || c.Available() {
+		t.Errorf("expected an unmigrated database to be unavailable, got %v", err)
+	}
+
+	if _, err := Migrate(context.Background(), db, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Check(context.Background()); err != nil || !c.Available() {
+		t.Errorf("expected a migrated database to be available, got %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB.Close()
+	if err := c.Check(context.Background()); !errors.Is(err, Unavailable) || c.Available() {
+		t.Errorf("expected a closed database to be unavailable, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold is the duration above which the queries are logged.
+const slowQueryThreshold = 200 * time.Millisecond
+
+// contextLogger logs the failed and slow queries with the logger of their
+// context, so that they carry the values of the request or the reconciliation
+// running them, and with the fallback logger otherwise.
+type contextLogger struct {
+	fallback logr.Logger
+	level    gormlogger.LogLevel
+}
+
+func NewLogger(fallback logr.Logger) gormlogger.Interface {
+	return &contextLogger{
+		fallback: fallback,
+		level:    gormlogger.Warn,
+	}
+}
+
+func (l *contextLogger) from(ctx context.Context) logr.Logger {
+	if ctxLogger, err := logr.FromContext(ctx); err == nil {
+		return ctxLogger.WithName("database")
+	}
+
+	return l.fallback
+}
+
+func (l *contextLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &contextLogger{
+		fallback: l.fallback,
+		level:    level,
+	}
+}
+
+func (l *contextLogger) Info(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Info {
+		l.from(ctx).Info(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *contextLogger) Warn(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Warn {
+		l.from(ctx).Info(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *contextLogger) Error(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Error {
+		l.from(ctx).Error(nil, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *contextLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound) &&
+		!errors.Is(err, context.Canceled):
+		sql, rows := fc()
+		l.from(ctx).Error(err, "query failed", "sql", sql, "rows", rows, "elapsed", elapsed)
+	case elapsed > slowQueryThreshold && l.level >= gormlogger.Warn:
+		sql, rows := fc()
+		l.from(ctx).Info("slow query", "sql", sql, "rows", rows, "elapsed", elapsed)
+	case l.level >= gormlogger.Info:
+		sql, rows := fc()
+		l.from(ctx).V(1).Info("query", "sql", sql, "rows", rows, "elapsed", elapsed)
+	}
+}
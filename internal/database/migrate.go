@@ -0,0 +1,310 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations
+var migrationsFS embed.FS
+
+// migrationLockKey identifies the advisory lock held while migrating.
+const migrationLockKey = 0x5c4e6e52
+
+// BackfillFunc migrates data which cannot be expressed in SQL. It runs in the
+// same transaction as the SQL part of the migration with the same version, or
+// after it on the databases committing DDL implicitly.
+type BackfillFunc func(tx *gorm.DB) error
+
+type Migration struct {
+	Version  int
+	Name     string
+	SQL      string
+	Backfill BackfillFunc
+}
+
+// SchemaVersion records an applied migration.
+type SchemaVersion struct {
+	Version   int       `gorm:"primarykey;autoIncrement:false"`
+	Name      string    `gorm:"not null;size:255"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// SchemaStatement records a statement of a migration which is not applied
+// completely yet, on the databases committing DDL implicitly.
+type SchemaStatement struct {
+	Version   int `gorm:"primarykey;autoIncrement:false"`
+	Statement int `gorm:"primarykey;autoIncrement:false"`
+}
+
+func (SchemaStatement) TableName() string {
+	return "schema_statements"
+}
+
+// commitsDDL tells whether the database commits the transaction implicitly
+// before and after DDL statements, so a migration cannot be rolled back.
+func commitsDDL(databaseType DatabaseType) bool {
+	return databaseType == MySQL
+}
+
+// Migrations returns the migrations of a database type ordered by version.
+// Files are named after their version and name, e.g. 0001_create_scan_results.sql.
+func Migrations(databaseType DatabaseType, backfills map[int]BackfillFunc) ([]Migration, error) {
+	dir := path.Join("migrations", string(databaseType))
+	entries, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported database type: %s", databaseType)
+	}
+
+	migrations := []Migration{}
+	for _, entry := range entries {
+		versionStr, name, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		sql, err := fs.ReadFile(migrationsFS, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     name,
+			SQL:      string(sql),
+			Backfill: backfills[version],
+		})
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return a.Version - b.Version
+	})
+
+	return migrations, nil
+}
+
+// Migrate applies the pending migrations and returns them. Only one replica
+// migrates at a time, the others wait for the lock and find nothing to do. In
+// dry-run mode the pending migrations are returned without being applied.
+// The backfills get the context through the statement of the transaction.
+func Migrate(ctx context.Context, db *gorm.DB, backfills map[int]BackfillFunc, dryRun bool) ([]Migration, error) {
+	db = db.WithContext(ctx)
+	databaseType := DatabaseType(db.Dialector.Name())
+	migrations, err := Migrations(databaseType, backfills)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	err = db.Connection(func(conn *gorm.DB) error {
+		// Every statement has to run on the pinned connection holding the lock
+		// without sharing the statement of the instance returned by Connection.
+		conn = conn.Session(&gorm.Session{NewDB: true})
+		unlock, err := lock(conn, databaseType)
+		if err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+
+		defer unlock()
+
+		if !conn.Migrator().HasTable(&SchemaVersion{}) {
+			if dryRun {
+				pending = migrations
+				return nil
+			}
+
+			if err := conn.Migrator().CreateTable(&SchemaVersion{}); err != nil {
+				return fmt.Errorf("failed to create schema_version table: %w", err)
+			}
+		}
+
+		if !dryRun && commitsDDL(databaseType) && !conn.Migrator().HasTable(&SchemaStatement{}) {
+			if err := conn.Migrator().CreateTable(&SchemaStatement{}); err != nil {
+				return fmt.Errorf("failed to create schema_statements table: %w", err)
+			}
+		}
+
+		current, err := currentVersion(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			if migration.Version <= current {
+				continue
+			}
+
+			pending = append(pending, migration)
+			if dryRun {
+				continue
+			}
+
+			if commitsDDL(databaseType) {
+				err = applyByStatement(conn, migration)
+			} else {
+				err = conn.Transaction(func(tx *gorm.DB) error {
+					return apply(tx, migration)
+				})
+			}
+
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+		}
+
+		return nil
+	})
+
+	return pending, err
+}
+
+// CurrentSchemaVersion returns the version of the latest applied migration.
+func CurrentSchemaVersion(db *gorm.DB) (int, error) {
+	if !db.Migrator().HasTable(&SchemaVersion{}) {
+		return 0, nil
+	}
+
+	return currentVersion(db)
+}
+
+// LatestSchemaVersion returns the version of the latest known migration.
+func LatestSchemaVersion(databaseType DatabaseType) (int, error) {
+	migrations, err := Migrations(databaseType, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+
+	return migrations[len(migrations)-1].Version, nil
+}
+
+func currentVersion(db *gorm.DB) (int, error) {
+	var version int
+	if err := db.Model(&SchemaVersion{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+func statements(sql string) []string {
+	return slices.DeleteFunc(strings.Split(sql, ";\n"), func(statement string) bool {
+		return strings.TrimSpace(statement) == ""
+	})
+}
+
+func apply(tx *gorm.DB, migration Migration) error {
+	for _, statement := range statements(migration.SQL) {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	return complete(tx, migration)
+}
+
+// applyByStatement applies the statements of the migration one by one, each
+// in a transaction recording it in schema_statements, as the statements
+// cannot be rolled back together. The statements recorded by a previous
+// attempt failing partway through are skipped. A DDL statement is committed
+// before its record, so only a crash in between applies it without one.
+func applyByStatement(conn *gorm.DB, migration Migration) error {
+	var applied []int
+	if err := conn.Model(&SchemaStatement{}).Where("version = ?", migration.Version).
+		Pluck("statement", &applied).Error; err != nil {
+		return fmt.Errorf("failed to get applied statements: %w", err)
+	}
+
+	for i, statement := range statements(migration.SQL) {
+		if slices.Contains(applied, i) {
+			continue
+		}
+
+		if err := conn.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+
+			return tx.Create(&SchemaStatement{Version: migration.Version, Statement: i}).Error
+		}); err != nil {
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+	}
+
+	return conn.Transaction(func(tx *gorm.DB) error {
+		if err := complete(tx, migration); err != nil {
+			return err
+		}
+
+		return tx.Where("version = ?", migration.Version).Delete(&SchemaStatement{}).Error
+	})
+}
+
+// complete runs the backfill of the migration and records it as applied.
+func complete(tx *gorm.DB, migration Migration) error {
+	if migration.Backfill != nil {
+		if err := migration.Backfill(tx); err != nil {
+			return err
+		}
+	}
+
+	return tx.Create(&SchemaVersion{
+		Version:   migration.Version,
+		Name:      migration.Name,
+		AppliedAt: time.Now(),
+	}).Error
+}
+
+// lock acquires a lock held by the connection until the returned function is
+// called. SQLite databases are not shared between replicas, so they are not
+// locked.
+func lock(conn *gorm.DB, databaseType DatabaseType) (func(), error) {
+	switch databaseType {
+	case PostgreSQL:
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockKey).Error; err != nil {
+			return nil, err
+		}
+
+		return func() {
+			conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockKey)
+		}, nil
+	case MySQL:
+		var acquired int
+		name := strconv.Itoa(migrationLockKey)
+		if err := conn.Raw("SELECT GET_LOCK(?, 600)", name).Scan(&acquired).Error; err != nil {
+			return nil, err
+		}
+
+		if acquired != 1 {
+			return nil, errors.New("timed out waiting for lock")
+		}
+
+		return func() {
+			conn.Exec("SELECT RELEASE_LOCK(?)", name)
+		}, nil
+	default:
+		return func() {}, nil
+	}
+}
@@ -0,0 +1,67 @@
+//go:build integration
+
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestMigrateDialects applies the migrations of postgres and mysql to the
+// empty databases of TEST_POSTGRES_DSN and TEST_MYSQL_DSN, e.g. started with
+//
+//	docker run -e POSTGRES_PASSWORD=scanner -p 5432:5432 postgres:16
+//	docker run -e MYSQL_ROOT_PASSWORD=scanner -e MYSQL_DATABASE=scanner -p 3306:3306 mysql:8
+//
+// and run with go test -tags integration ./internal/database/.
+func TestMigrateDialects(t *testing.T) {
+	for databaseType, env := range map[DatabaseType]string{
+		PostgreSQL: "TEST_POSTGRES_DSN",
+		MySQL:      "TEST_MYSQL_DSN",
+	} {
+		t.Run(string(databaseType), func(t *testing.T) {
+			dsn := os.Getenv(env)
+			if dsn == "" {
+				t.Skipf("%s not set", env)
+			}
+
+			dialector, err := GetDialector(string(databaseType), dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Discard})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			latest, err := LatestSchemaVersion(databaseType)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if current, err := CurrentSchemaVersion(db); err != nil || current != 0 {
+				t.Fatalf("expected an empty database, got schema version %d (%v)", current, err)
+			}
+
+			applied, err := Migrate(context.Background(), db, nil, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(applied) != latest {
+				t.Fatalf("expected %d migrations to be applied, got %d", latest, len(applied))
+			}
+
+			if applied, err := Migrate(context.Background(), db, nil, false); err != nil || len(applied) != 0 {
+				t.Fatalf("expected migrations to be idempotent, got %d (%v)", len(applied), err)
+			}
+
+			checkSchemaMatchesModels(t, db)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+func TestMigrate(t *testing.T) {
+	db := newTestDatabase(t)
+	latest, err := LatestSchemaVersion(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := Migrate(context.Background(), db, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != latest || db.Migrator().HasTable(&ScanResult{}) {
+		t.Fatalf("dry run should list %d migrations without applying them, got %d", latest, len(pending))
+	}
+
+	applied, err := Migrate(context.Background(), db, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(applied) != latest {
+		t.Fatalf("expected %d migrations to be applied, got %d", latest, len(applied))
+	}
+
+	if current, err := CurrentSchemaVersion(db); err != nil || current != latest {
+		t.Fatalf("expected schema version %d, got %d (%v)", latest, current, err)
+	}
+
+	if applied, err := Migrate(context.Background(), db, nil, false); err != nil || len(applied) != 0 {
+		t.Fatalf("expected migrations to be idempotent, got %d (%v)", len(applied), err)
+	}
+
+	checkSchemaMatchesModels(t, db)
+}
+
+// checkSchemaMatchesModels fails unless the tables have every column of the
+// models.
+func checkSchemaMatchesModels(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	for _, model := range []any{&ScanResult{}, &ScanTrigger{}, &Scan{}, &Component{}, &Vulnerability{}, &Report{}, &ReportBlob{}, &EventRecord{}, &UsedUploadToken{}, &ImageNamespace{}} {
+		statement := &gorm.Statement{DB: db}
+		if err := statement.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, field := range statement.Schema.DBNames {
+			if !db.Migrator().HasColumn(model, field) {
+				t.Errorf("column %s of %T is missing", field, model)
+			}
+		}
+	}
+}
+
+func TestMigrateKeepsExistingScanResults(t *testing.T) {
+	db := newTestDatabase(t)
+	if err := db.Exec("CREATE TABLE scan_results (image_id TEXT PRIMARY KEY, report TEXT NOT NULL)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Exec("INSERT INTO scan_results VALUES ('alpine', '{}')").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	backfilled := false
+	backfills := map[int]BackfillFunc{
+		2: func(tx *gorm.DB) error {
+			backfilled = true
+			return nil
+		},
+	}
+
+	if _, err := Migrate(context.Background(), db, backfills, false); err != nil {
+		t.Fatal(err)
+	}
+
+	scanResult := ScanResult{}
+	if err := db.First(&scanResult, "image_id = ?", "alpine").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if !backfilled {
+		t.Error("expected the backfill of migration 2 to run")
+	}
+}
+
+func TestApplyByStatementResumes(t *testing.T) {
+	db := newTestDatabase(t)
+	if err := db.Migrator().CreateTable(&SchemaVersion{}, &SchemaStatement{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second statement fails until its table exists, after the first one
+	// was committed.
+	migration := Migration{
+		Version: 1,
+		Name:    "create_a",
+		SQL:     "CREATE TABLE a (id INTEGER);\nINSERT INTO b VALUES (1);\n",
+	}
+	if err := applyByStatement(db, migration); err == nil {
+		t.Fatal("expected the second statement to fail")
+	}
+
+	if current, err := CurrentSchemaVersion(db); err != nil || current != 0 {
+		t.Fatalf("expected the migration not to be recorded, got %d (%v)", current, err)
+	}
+
+	if err := db.Exec("CREATE TABLE b (id INTEGER)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Creating a again would fail.
+	if err := applyByStatement(db, migration); err != nil {
+		t.Fatal(err)
+	}
+
+	if current, err := CurrentSchemaVersion(db); err != nil || current != 1 {
+		t.Errorf("expected the migration to be recorded, got %d (%v)", current, err)
+	}
+
+	var count int64
+	if err := db.Model(&SchemaStatement{}).Count(&count).Error; err != nil || count != 0 {
+		t.Errorf("expected the statements of the applied migration to be forgotten, got %d (%v)", count, err)
+	}
+}
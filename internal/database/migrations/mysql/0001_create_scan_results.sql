@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS scan_results (
+    image_id VARCHAR(512) PRIMARY KEY,
+    report LONGTEXT NOT NULL
+);
@@ -0,0 +1,16 @@
+ALTER TABLE scan_results ADD COLUMN scanner_name VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE scan_results ADD COLUMN scanner_version VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE scan_results ADD COLUMN database_version VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE scan_results ADD COLUMN scan_duration BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE scan_results ADD COLUMN created_at DATETIME(3);
+ALTER TABLE scan_results ADD COLUMN updated_at DATETIME(3);
+ALTER TABLE scans ADD COLUMN scan_duration BIGINT NOT NULL DEFAULT 0;
+CREATE TABLE scan_triggers (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    image_id VARCHAR(512) NOT NULL,
+    namespace VARCHAR(253) NOT NULL,
+    pod VARCHAR(253) NOT NULL,
+    container VARCHAR(253) NOT NULL
+);
+CREATE INDEX idx_scan_triggers_image_id ON scan_triggers (image_id);
+CREATE INDEX idx_scan_triggers_namespace ON scan_triggers (namespace);
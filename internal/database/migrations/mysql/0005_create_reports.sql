@@ -0,0 +1,11 @@
+CREATE TABLE reports (
+    hash VARCHAR(64) PRIMARY KEY,
+    data LONGBLOB NOT NULL,
+    size BIGINT NOT NULL,
+    compressed_size BIGINT NOT NULL,
+    created_at DATETIME(3) NOT NULL
+);
+ALTER TABLE scan_results ADD COLUMN report_hash VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE scans ADD COLUMN report_hash VARCHAR(64) NOT NULL DEFAULT '';
+CREATE INDEX idx_scan_results_report_hash ON scan_results (report_hash);
+CREATE INDEX idx_scans_report_hash ON scans (report_hash);
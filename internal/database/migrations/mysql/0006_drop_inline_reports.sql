@@ -0,0 +1,2 @@
+ALTER TABLE scan_results DROP COLUMN report;
+ALTER TABLE scans DROP COLUMN report;
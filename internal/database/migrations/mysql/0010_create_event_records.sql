@@ -0,0 +1,6 @@
+CREATE TABLE event_records (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    payload TEXT NOT NULL,
+    created_at DATETIME(3) NOT NULL
+);
+CREATE INDEX idx_event_records_created_at ON event_records (created_at);
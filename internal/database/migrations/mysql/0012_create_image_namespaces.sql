@@ -0,0 +1,9 @@
+CREATE TABLE image_namespaces (
+    image_id VARCHAR(512) NOT NULL,
+    namespace VARCHAR(253) NOT NULL,
+    created_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (image_id, namespace)
+);
+CREATE INDEX idx_image_namespaces_namespace ON image_namespaces (namespace);
+INSERT INTO image_namespaces (image_id, namespace, created_at)
+SELECT DISTINCT image_id, namespace, CURRENT_TIMESTAMP FROM scan_triggers;
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS scan_results (
+    image_id TEXT PRIMARY KEY,
+    report TEXT NOT NULL
+);
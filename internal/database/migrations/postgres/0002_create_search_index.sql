@@ -0,0 +1,20 @@
+CREATE TABLE components (
+    id BIGSERIAL PRIMARY KEY,
+    image_id VARCHAR(512) NOT NULL,
+    name TEXT NOT NULL,
+    version TEXT NOT NULL,
+    purl VARCHAR(512) NOT NULL,
+    purl_base VARCHAR(512) NOT NULL
+);
+CREATE INDEX idx_components_image_id ON components (image_id);
+CREATE INDEX idx_components_purl ON components (purl);
+CREATE INDEX idx_components_purl_base ON components (purl_base);
+CREATE TABLE vulnerabilities (
+    id BIGSERIAL PRIMARY KEY,
+    image_id VARCHAR(512) NOT NULL,
+    vulnerability_id VARCHAR(128) NOT NULL,
+    severity VARCHAR(16) NOT NULL,
+    purl VARCHAR(512) NOT NULL
+);
+CREATE INDEX idx_vulnerabilities_image_id ON vulnerabilities (image_id);
+CREATE INDEX idx_vulnerabilities_vulnerability_id ON vulnerabilities (vulnerability_id);
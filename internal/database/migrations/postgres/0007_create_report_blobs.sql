@@ -0,0 +1,6 @@
+CREATE TABLE report_blobs (
+    hash VARCHAR(64) PRIMARY KEY,
+    data BYTEA NOT NULL
+);
+INSERT INTO report_blobs (hash, data) SELECT hash, data FROM reports;
+ALTER TABLE reports DROP COLUMN data;
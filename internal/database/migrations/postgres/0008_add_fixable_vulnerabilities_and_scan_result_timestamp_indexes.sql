@@ -0,0 +1,6 @@
+ALTER TABLE vulnerabilities ADD COLUMN fixable BOOLEAN NOT NULL DEFAULT FALSE;
+-- The ScanResults are sorted by their timestamps, which are not null from now on.
+UPDATE scan_results SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+UPDATE scan_results SET updated_at = CURRENT_TIMESTAMP WHERE updated_at IS NULL;
+CREATE INDEX idx_scan_results_created_at ON scan_results (created_at);
+CREATE INDEX idx_scan_results_updated_at ON scan_results (updated_at);
@@ -0,0 +1 @@
+ALTER TABLE scan_results ADD COLUMN rescan_requested BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,10 @@
+CREATE TABLE scans (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    image_id VARCHAR(512) NOT NULL,
+    report TEXT NOT NULL,
+    scanner_name VARCHAR(64) NOT NULL DEFAULT '',
+    scanner_version VARCHAR(64) NOT NULL DEFAULT '',
+    database_version VARCHAR(64) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX idx_scans_image_id ON scans (image_id);
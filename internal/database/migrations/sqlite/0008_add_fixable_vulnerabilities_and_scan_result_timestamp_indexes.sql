@@ -0,0 +1,8 @@
+ALTER TABLE vulnerabilities ADD COLUMN fixable BOOLEAN NOT NULL DEFAULT FALSE;
+-- The ScanResults are sorted by their timestamps, which are not null from now on.
+-- Timestamps are stored in the format the driver binds time.Time values in,
+-- so that they compare correctly against cursors.
+UPDATE scan_results SET created_at = strftime('%Y-%m-%d %H:%M:%S+00:00', 'now') WHERE created_at IS NULL;
+UPDATE scan_results SET updated_at = strftime('%Y-%m-%d %H:%M:%S+00:00', 'now') WHERE updated_at IS NULL;
+CREATE INDEX idx_scan_results_created_at ON scan_results (created_at);
+CREATE INDEX idx_scan_results_updated_at ON scan_results (updated_at);
@@ -0,0 +1,13 @@
+ALTER TABLE scan_results ADD COLUMN critical_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_results ADD COLUMN high_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_results ADD COLUMN medium_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_results ADD COLUMN low_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_results ADD COLUMN info_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_results ADD COLUMN unknown_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_results ADD COLUMN fixable_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_results ADD COLUMN max_cvss_score REAL NOT NULL DEFAULT 0;
+ALTER TABLE scan_results ADD COLUMN component_count INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX idx_scan_results_critical_count ON scan_results (critical_count);
+CREATE INDEX idx_scan_results_high_count ON scan_results (high_count);
+CREATE INDEX idx_scan_results_fixable_count ON scan_results (fixable_count);
+CREATE INDEX idx_scan_results_max_cvss_score ON scan_results (max_cvss_score);
@@ -0,0 +1,6 @@
+CREATE TABLE event_records (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    payload TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX idx_event_records_created_at ON event_records (created_at);
@@ -0,0 +1,5 @@
+CREATE TABLE used_upload_tokens (
+    id VARCHAR(64) PRIMARY KEY,
+    expires_at DATETIME NOT NULL
+);
+CREATE INDEX idx_used_upload_tokens_expires_at ON used_upload_tokens (expires_at);
@@ -1,6 +1,130 @@
 package database
 
+import "time"
+
+// ScanResult is the latest scan of an image. Report is loaded from the Report
+// referenced by ReportHash.
 type ScanResult struct {
-	ImageID string `gorm:"primarykey;type:TEXT"`
-	Report  string `gorm:"not null;type:TEXT"`
+	ImageID              string `gorm:"primarykey;type:TEXT"`
+	Report               string `gorm:"-"`
+	ReportHash           string `gorm:"not null;size:64;index"`
+	ScanMetadata         `gorm:"embedded"`
+	VulnerabilitySummary `gorm:"embedded"`
+	Triggers             []ScanTrigger `gorm:"foreignKey:ImageID;references:ImageID"`
+	// RescanRequested makes the Scanners scan the image again, it is cleared
+	// when the next report is uploaded.
+	RescanRequested bool `gorm:"not null;default:false"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// VulnerabilitySummary counts the findings of a report by severity, so that
+// they can be listed and filtered without decoding the report. InfoCount
+// includes the findings of severity none.
+type VulnerabilitySummary struct {
+	CriticalCount  int     `gorm:"not null;default:0;index"`
+	HighCount      int     `gorm:"not null;default:0;index"`
+	MediumCount    int     `gorm:"not null;default:0"`
+	LowCount       int     `gorm:"not null;default:0"`
+	InfoCount      int     `gorm:"not null;default:0"`
+	UnknownCount   int     `gorm:"not null;default:0"`
+	FixableCount   int     `gorm:"not null;default:0;index"`
+	MaxCVSSScore   float64 `gorm:"column:max_cvss_score;not null;default:0;index"`
+	ComponentCount int     `gorm:"not null;default:0"`
+}
+
+// ScanMetadata describes how a report was produced. ScanDuration is measured
+// from the creation of the scan Job until the upload of the report.
+type ScanMetadata struct {
+	ScannerName     string        `gorm:"size:64"`
+	ScannerVersion  string        `gorm:"size:64"`
+	DatabaseVersion string        `gorm:"size:64"`
+	ScanDuration    time.Duration `gorm:"default:0"`
+}
+
+// ScanTrigger is a container running the image at the time it was scanned.
+type ScanTrigger struct {
+	ID        uint   `gorm:"primarykey"`
+	ImageID   string `gorm:"not null;size:512;index"`
+	Namespace string `gorm:"not null;size:253;index"`
+	Pod       string `gorm:"not null;size:253"`
+	Container string `gorm:"not null;size:253"`
+}
+
+// ImageNamespace records that an image was running in a namespace, either
+// when it was scanned or while it was reconciled by the Scanner of the
+// namespace. The ScanResults of an image are visible to the callers having
+// access to any of its namespaces.
+type ImageNamespace struct {
+	ImageID   string    `gorm:"primarykey;size:512"`
+	Namespace string    `gorm:"primarykey;size:253;index"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// Scan is a single report uploaded for an image. While ScanResult only holds
+// the latest report, every Scan is kept to be able to follow how the
+// vulnerabilities of an image changed over time.
+type Scan struct {
+	ID           uint   `gorm:"primarykey"`
+	ImageID      string `gorm:"not null;size:512;index"`
+	Report       string `gorm:"-"`
+	ReportHash   string `gorm:"not null;size:64;index"`
+	ScanMetadata `gorm:"embedded"`
+	CreatedAt    time.Time `gorm:"not null"`
+}
+
+// Report describes a gzip compressed report stored once no matter how many
+// ScanResults and Scans reference it. Hash is the hex encoded SHA-256 of the
+// uncompressed report, whose length is Size. The report itself is kept in the
+// configured report store.
+type Report struct {
+	Hash           string    `gorm:"primarykey;size:64"`
+	Size           int64     `gorm:"not null"`
+	CompressedSize int64     `gorm:"not null"`
+	CreatedAt      time.Time `gorm:"not null"`
+}
+
+// ReportBlob is a compressed report kept in the database report store.
+type ReportBlob struct {
+	Hash string `gorm:"primarykey;size:64"`
+	Data []byte `gorm:"not null"`
+}
+
+// EventRecord is an event published by a replica for the other replicas
+// polling the database for events.
+type EventRecord struct {
+	ID        uint64    `gorm:"primarykey"`
+	Payload   string    `gorm:"not null;type:TEXT"`
+	CreatedAt time.Time `gorm:"not null;index"`
+}
+
+// UsedUploadToken is an upload token of a scan Job that was used already, kept
+// until it expires to reject it when used again.
+type UsedUploadToken struct {
+	ID        string    `gorm:"primarykey;size:64"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+// Component is a package found in the report of an image. Purl is the package
+// URL without qualifiers and subpath, while PurlBase also omits the version.
+type Component struct {
+	ID       uint   `gorm:"primarykey"`
+	ImageID  string `gorm:"not null;size:512;index"`
+	Name     string `gorm:"not null;type:TEXT"`
+	Version  string `gorm:"not null;type:TEXT"`
+	Purl     string `gorm:"not null;size:512;index"`
+	PurlBase string `gorm:"not null;size:512;index"`
+}
+
+// Vulnerability is a finding in the report of an image. VulnerabilityID is
+// either the identifier of the advisory or one of its aliases, so an image
+// matched by a GHSA advisory can also be found by the related CVE. Fixable is
+// set when the scanner recommends an upgrade fixing the vulnerability.
+type Vulnerability struct {
+	ID              uint   `gorm:"primarykey"`
+	ImageID         string `gorm:"not null;size:512;index"`
+	VulnerabilityID string `gorm:"not null;size:128;index"`
+	Severity        string `gorm:"not null;size:16"`
+	Purl            string `gorm:"not null;size:512"`
+	Fixable         bool   `gorm:"not null;default:false"`
 }
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+func TestTracingPlugin(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	db := newTestDatabase(t)
+	if err := db.Use(newTracingPlugin(provider)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	if err := db.WithContext(ctx).Exec("CREATE TABLE images (id TEXT)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.WithContext(ctx).Table("missing").Create(map[string]any{"id": "alpine"}).Error; err == nil {
+		t.Fatal("expected insert into missing table to fail")
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+
+	raw, create := spans[0], spans[1]
+	if raw.Name() != "gorm.raw" || create.Name() != "gorm.create" {
+		t.Errorf("unexpected span names %q and %q", raw.Name(), create.Name())
+	}
+
+	for _, span := range []sdktrace.ReadOnlySpan{raw, create} {
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %q is not a child of the request", span.Name())
+		}
+	}
+
+	attributes := map[string]string{}
+	for _, attribute := range raw.Attributes() {
+		attributes[string(attribute.Key)] = attribute.Value.Emit()
+	}
+
+	if attributes[string(semconv.DBSystemKey)] != "sqlite" || attributes[string(semconv.DBStatementKey)] != "CREATE TABLE images (id TEXT)" {
+		t.Errorf("unexpected attributes %v", attributes)
+	}
+
+	if create.Status().Code != codes.Error || len(create.Events()) == 0 {
+		t.Errorf("expected the failed insert to be recorded, got %v", create.Status())
+	}
+}
@@ -0,0 +1,112 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"github.com/kerezsiz42/scanner-operator2/internal/utils"
+)
+
+type BusType string
+
+const (
+	Memory   BusType = "memory"
+	Postgres BusType = "postgres"
+	Polling  BusType = "polling"
+)
+
+// Bus carries the events between the replicas of the operator, so that the
+// subscribers connected to any replica receive the events published by the
+// others. The events published by a replica are not delivered back to it.
+type Bus interface {
+	Publish(ctx context.Context, event Event) error
+	// Run calls handler with the events published by the other replicas until
+	// the context is cancelled.
+	Run(ctx context.Context, handler func(Event)) error
+}
+
+// Options selects the Bus.
+type Options struct {
+	Type BusType
+	// PollInterval is how often the polling Bus looks for new events.
+	PollInterval time.Duration
+}
+
+// GetBus returns the Bus selected by the options, defaulting to the in-memory
+// Bus, which is enough for a single replica. The polling Bus works with every
+// database.
+func GetBus(db *gorm.DB, options Options) (Bus, error) {
+	switch options.Type {
+	case "", Memory:
+		return NewMemoryBus().Member(), nil
+	case Postgres:
+		if database.DatabaseType(db.Dialector.Name()) != database.PostgreSQL {
+			return nil, fmt.Errorf("the %s event bus requires a PostgreSQL database", Postgres)
+		}
+
+		return NewPostgresBus(db), nil
+	case Polling:
+		interval := options.PollInterval
+		if interval <= 0 {
+			interval = defaultPollInterval
+		}
+
+		return NewPollingBus(db, interval), nil
+	default:
+		return nil, fmt.Errorf("unsupported event bus: %s", options.Type)
+	}
+}
+
+// message is an Event on the wire together with the replica publishing it.
+// The sequence is left out, every replica numbers the events itself, but the
+// ID given by the publishing replica is kept.
+type message struct {
+	Origin     string                         `json:"origin"`
+	ID         string                         `json:"id,omitempty"`
+	Type       Type                           `json:"type"`
+	ImageID    string                         `json:"imageId"`
+	Namespaces []string                       `json:"namespaces,omitempty"`
+	Summary    *database.VulnerabilitySummary `json:"summary,omitempty"`
+	Timestamp  time.Time                      `json:"timestamp"`
+}
+
+func newOrigin() string {
+	return utils.GenerateId()
+}
+
+func encodeMessage(origin string, event Event) ([]byte, error) {
+	return json.Marshal(message{
+		Origin:     origin,
+		ID:         event.ID,
+		Type:       event.Type,
+		ImageID:    event.ImageID,
+		Namespaces: event.Namespaces,
+		Summary:    event.Summary,
+		Timestamp:  event.Timestamp,
+	})
+}
+
+// decodeMessage returns the event of the message, and false if it was
+// published by the origin itself.
+func decodeMessage(origin string, data []byte) (Event, bool, error) {
+	m := message{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Event{}, false, fmt.Errorf("failed to decode event: %w", err)
+	}
+
+	event := Event{
+		ID:         m.ID,
+		Type:       m.Type,
+		ImageID:    m.ImageID,
+		Namespaces: m.Namespaces,
+		Summary:    m.Summary,
+		Timestamp:  m.Timestamp,
+	}
+
+	return event, m.Origin != origin, nil
+}
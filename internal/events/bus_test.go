@@ -0,0 +1,127 @@
+package events
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+)
+
+// collect runs the Bus and returns the events it delivers.
+func collect(t *testing.T, bus Bus) func() []Event {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	mu := sync.Mutex{}
+	received := []Event{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := bus.Run(ctx, func(event Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, event)
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return func() []Event {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Event{}, received...)
+	}
+}
+
+func waitForEvents(t *testing.T, received func() []Event, n int) []Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(received()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d events, got %d", n, len(received()))
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return received()
+}
+
+func testBus(t *testing.T, a Bus, b Bus) {
+	t.Helper()
+	receivedA := collect(t, a)
+	receivedB := collect(t, b)
+
+	// The buses start listening asynchronously.
+	time.Sleep(100 * time.Millisecond)
+
+	event := Event{
+		Type:       ScanResultCreated,
+		ImageID:    "alpine",
+		Namespaces: []string{"default"},
+		Summary:    &database.VulnerabilitySummary{CriticalCount: 1, MaxCVSSScore: 9.8},
+		Timestamp:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		ID:         "origin-7",
+	}
+	if err := a.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Publish(context.Background(), Event{Type: ScanStarted, ImageID: "debian"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if received := waitForEvents(t, receivedB, 1); !reflect.DeepEqual(received[0], event) {
+		t.Errorf("unexpected event: %+v", received[0])
+	}
+
+	if received := waitForEvents(t, receivedA, 1); received[0].ImageID != "debian" {
+		t.Errorf("unexpected event: %+v", received[0])
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if len(receivedA()) != 1 || len(receivedB()) != 1 {
+		t.Errorf("expected the events not to be delivered to the publisher")
+	}
+}
+
+func TestMemoryBus(t *testing.T) {
+	bus := NewMemoryBus()
+	testBus(t, bus.Member(), bus.Member())
+}
+
+func TestPollingBus(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB.SetMaxOpenConns(1)
+	if _, err := database.Migrate(context.Background(), db, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Events published before the bus is running are not delivered.
+	if err := NewPollingBus(db, time.Second).Publish(context.Background(), Event{Type: ScanFailed}); err != nil {
+		t.Fatal(err)
+	}
+
+	testBus(t, NewPollingBus(db, 10*time.Millisecond), NewPollingBus(db, 10*time.Millisecond))
+}
@@ -0,0 +1,42 @@
+package events
+
+import (
+	"time"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+)
+
+// Version is the version of the Event envelope sent to the subscribers. It is
+// increased on incompatible changes of the envelope.
+const Version = 1
+
+// Type tells what happened to the image of an Event.
+type Type string
+
+const (
+	ScanResultCreated Type = "scanResultCreated"
+	ScanResultUpdated Type = "scanResultUpdated"
+	ScanResultDeleted Type = "scanResultDeleted"
+	ScanStarted       Type = "scanStarted"
+	ScanFailed        Type = "scanFailed"
+)
+
+// Event is a change of a ScanResult or of a scan. Namespaces are the
+// namespaces the image was running in, which decide who can see the Event.
+// Summary is only set for ScanResultCreated and ScanResultUpdated events.
+// Timestamp, Sequence and ID are set by the Publisher when left empty.
+// Sequence numbers the Events of a replica, while ID identifies the Event on
+// every replica it is delivered to.
+type Event struct {
+	Type       Type
+	ImageID    string
+	Namespaces []string
+	Summary    *database.VulnerabilitySummary
+	Timestamp  time.Time
+	Sequence   uint64
+	ID         string
+}
+
+type Publisher interface {
+	Publish(event Event)
+}
@@ -0,0 +1,56 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBus connects the replicas running in the same process. In production
+// this is only the case with a single replica, having no other replicas to
+// deliver to.
+type MemoryBus struct {
+	mu       sync.Mutex
+	handlers map[*memoryMember]func(Event)
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		handlers: map[*memoryMember]func(Event){},
+	}
+}
+
+// Member returns the Bus of a replica.
+func (b *MemoryBus) Member() Bus {
+	return &memoryMember{bus: b}
+}
+
+type memoryMember struct {
+	bus *MemoryBus
+}
+
+func (m *memoryMember) Publish(ctx context.Context, event Event) error {
+	m.bus.mu.Lock()
+	defer m.bus.mu.Unlock()
+
+	for member, handler := range m.bus.handlers {
+		if member != m {
+			handler(event)
+		}
+	}
+
+	return nil
+}
+
+func (m *memoryMember) Run(ctx context.Context, handler func(Event)) error {
+	m.bus.mu.Lock()
+	m.bus.handlers[m] = handler
+	m.bus.mu.Unlock()
+
+	<-ctx.Done()
+
+	m.bus.mu.Lock()
+	delete(m.bus.handlers, m)
+	m.bus.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+)
+
+const (
+	defaultPollInterval = 2 * time.Second
+	pollBatchSize       = 1000
+	// pollRetention is how long the events are kept for the replicas
+	// polling them.
+	pollRetention = time.Hour
+	pruneInterval = time.Minute
+)
+
+// PollingBus stores the events in the event_records table and polls it for
+// the events of the other replicas, for the databases without LISTEN/NOTIFY.
+// An event whose transaction commits after a later one was polled already is
+// missed, which is rare as each event is inserted on its own.
+type PollingBus struct {
+	db       *gorm.DB
+	origin   string
+	interval time.Duration
+}
+
+func NewPollingBus(db *gorm.DB, interval time.Duration) *PollingBus {
+	return &PollingBus{
+		db:       db,
+		origin:   newOrigin(),
+		interval: interval,
+	}
+}
+
+func (b *PollingBus) Publish(ctx context.Context, event Event) error {
+	data, err := encodeMessage(b.origin, event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	if err := b.db.WithContext(ctx).Create(&database.EventRecord{Payload: string(data)}).Error; err != nil {
+		return fmt.Errorf("failed to insert event: %w", err)
+	}
+
+	return nil
+}
+
+func (b *PollingBus) Run(ctx context.Context, handler func(Event)) error {
+	logger := log.FromContext(ctx)
+
+	// Only the events published from now on are delivered.
+	var lastID uint64
+	err := b.db.WithContext(ctx).Model(&database.EventRecord{}).Select("COALESCE(MAX(id), 0)").Scan(&lastID).Error
+	if err != nil {
+		return fmt.Errorf("failed to get the last event: %w", err)
+	}
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	lastPrune := time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if lastID, err = b.poll(ctx, lastID, handler); err != nil {
+			logger.Error(err, "failed to poll events")
+		}
+
+		if time.Since(lastPrune) > pruneInterval {
+			lastPrune = time.Now()
+			err := b.db.WithContext(ctx).Where("created_at < ?", time.Now().Add(-pollRetention)).
+				Delete(&database.EventRecord{}).Error
+			if err != nil {
+				logger.Error(err, "failed to prune events")
+			}
+		}
+	}
+}
+
+// poll delivers the events after lastID and returns the ID of the last one.
+func (b *PollingBus) poll(ctx context.Context, lastID uint64, handler func(Event)) (uint64, error) {
+	for {
+		records := []database.EventRecord{}
+		err := b.db.WithContext(ctx).Where("id > ?", lastID).Order("id").Limit(pollBatchSize).Find(&records).Error
+		if err != nil {
+			return lastID, err
+		}
+
+		for _, record := range records {
+			lastID = record.ID
+			event, foreign, err := decodeMessage(b.origin, []byte(record.Payload))
+			if err != nil {
+				log.FromContext(ctx).Error(err, "failed to receive event", "id", record.ID)
+				continue
+			}
+
+			if foreign {
+				handler(event)
+			}
+		}
+
+		if len(records) < pollBatchSize {
+			return lastID, nil
+		}
+	}
+}
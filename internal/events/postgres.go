@@ -0,0 +1,145 @@
+package events
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	postgresChannel = "scanner_events"
+	// maxNotifyPayload is the longest payload accepted by NOTIFY, which has to
+	// be shorter than 8000 bytes.
+	maxNotifyPayload = 7999
+)
+
+// PostgresBus sends the events with NOTIFY and receives them on a connection
+// of the pool dedicated to LISTEN. Events published while the connection is
+// being reestablished are lost.
+type PostgresBus struct {
+	db             *gorm.DB
+	origin         string
+	reconnectDelay time.Duration
+}
+
+func NewPostgresBus(db *gorm.DB) *PostgresBus {
+	return &PostgresBus{
+		db:             db,
+		origin:         newOrigin(),
+		reconnectDelay: 5 * time.Second,
+	}
+}
+
+// encodeNotification encodes the event as the payload of NOTIFY. The
+// namespaces are left out of the events exceeding maxNotifyPayload, which then
+// only reach the subscribers seeing every namespace, and true is returned.
+func encodeNotification(origin string, event Event) ([]byte, bool, error) {
+	data, err := encodeMessage(origin, event)
+	if err != nil || len(data) <= maxNotifyPayload {
+		return data, false, err
+	}
+
+	event.Namespaces = nil
+	if data, err = encodeMessage(origin, event); err != nil {
+		return nil, false, err
+	}
+
+	if len(data) > maxNotifyPayload {
+		return nil, false, fmt.Errorf("the event of %d bytes exceeds the NOTIFY payload limit of %d bytes", len(data), maxNotifyPayload)
+	}
+
+	return data, true, nil
+}
+
+func (b *PostgresBus) Publish(ctx context.Context, event Event) error {
+	data, truncated, err := encodeNotification(b.origin, event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	if truncated {
+		log.FromContext(ctx).Info("left out the namespaces of an event exceeding the NOTIFY payload limit",
+			"imageId", event.ImageID, "namespaces", len(event.Namespaces))
+	}
+
+	if err := b.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", postgresChannel, string(data)).Error; err != nil {
+		return fmt.Errorf("failed to notify %s: %w", postgresChannel, err)
+	}
+
+	return nil
+}
+
+func (b *PostgresBus) Run(ctx context.Context, handler func(Event)) error {
+	logger := log.FromContext(ctx)
+	for {
+		err := b.listen(ctx, handler)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		logger.Error(err, "lost the connection listening for events", "channel", postgresChannel)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(b.reconnectDelay):
+		}
+	}
+}
+
+func (b *PostgresBus) listen(ctx context.Context, handler func(Event)) error {
+	sqlDB, err := b.db.DB()
+	if err != nil {
+		return err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a connection: %w", err)
+	}
+
+	defer conn.Close()
+
+	var listenErr error
+	_ = conn.Raw(func(driverConn any) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			listenErr = fmt.Errorf("unsupported PostgreSQL driver: %T", driverConn)
+			return nil
+		}
+
+		listenErr = b.receive(ctx, stdlibConn, handler)
+		// The connection is still listening, so it must not be reused.
+		return driver.ErrBadConn
+	})
+
+	return listenErr
+}
+
+func (b *PostgresBus) receive(ctx context.Context, stdlibConn *stdlib.Conn, handler func(Event)) error {
+	pgxConn := stdlibConn.Conn()
+	if _, err := pgxConn.Exec(ctx, "LISTEN "+postgresChannel); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", postgresChannel, err)
+	}
+
+	for {
+		notification, err := pgxConn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		event, foreign, err := decodeMessage(b.origin, []byte(notification.Payload))
+		if err != nil {
+			log.FromContext(ctx).Error(err, "failed to receive event", "channel", postgresChannel)
+			continue
+		}
+
+		if foreign {
+			handler(event)
+		}
+	}
+}
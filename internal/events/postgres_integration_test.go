@@ -0,0 +1,89 @@
+//go:build integration
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gorm.io/gorm"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+)
+
+// newTestPostgresBus connects to the database of TEST_POSTGRES_DSN through a
+// DSN file, like the operator does with a mounted Secret.
+func newTestPostgresBus(t *testing.T) (*PostgresBus, *gorm.DB) {
+	t.Helper()
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+
+	dsnFile := filepath.Join(t.TempDir(), "dsn")
+	if err := os.WriteFile(dsnFile, []byte(dsn), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.GetDatabase(database.Options{Type: database.PostgreSQL, DSNFile: dsnFile}, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus := NewPostgresBus(db)
+	bus.reconnectDelay = 100 * time.Millisecond
+	return bus, db
+}
+
+func TestPostgresBus(t *testing.T) {
+	a, _ := newTestPostgresBus(t)
+	b, db := newTestPostgresBus(t)
+	testBus(t, a, b)
+
+	received := collect(t, b)
+	time.Sleep(100 * time.Millisecond)
+
+	// The largest event fitting the payload of NOTIFY with its namespaces.
+	event := Event{Type: ScanStarted, ImageID: "alpine", Timestamp: time.Now().UTC()}
+	for i := 0; ; i++ {
+		namespaces := append(event.Namespaces, fmt.Sprintf("team-%d", i))
+		data, _ := encodeMessage(a.origin, Event{Type: event.Type, ImageID: event.ImageID, Namespaces: namespaces, Timestamp: event.Timestamp})
+		if len(data) > maxNotifyPayload {
+			break
+		}
+
+		event.Namespaces = namespaces
+	}
+
+	if err := a.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	if events := waitForEvents(t, received, 1); len(events[0].Namespaces) != len(event.Namespaces) {
+		t.Errorf("expected %d namespaces, got %d", len(event.Namespaces), len(events[0].Namespaces))
+	}
+
+	// The listening connections are dropped, after which the buses listen on
+	// new ones. The events published in the meantime are lost.
+	if err := db.Exec("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE query = ?", "LISTEN "+postgresChannel).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for len(received()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the events to be received after reconnecting")
+		}
+
+		if err := a.Publish(context.Background(), Event{Type: ScanStarted, ImageID: "debian"}); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,38 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEncodeNotification(t *testing.T) {
+	event := Event{Type: ScanStarted, ImageID: "alpine", Namespaces: []string{"default"}}
+	data, truncated, err := encodeNotification("origin", event)
+	if err != nil || truncated {
+		t.Fatalf("expected the event to fit, got %v", err)
+	}
+
+	if decoded, _, err := decodeMessage("other", data); err != nil || decoded.Namespaces[0] != "default" {
+		t.Errorf("expected the namespaces to be kept, got %+v (%v)", decoded, err)
+	}
+
+	// An image running in every namespace of a large cluster.
+	for i := 0; len(event.Namespaces) < 1000; i++ {
+		event.Namespaces = append(event.Namespaces, fmt.Sprintf("team-%d", i))
+	}
+
+	data, truncated, err = encodeNotification("origin", event)
+	if err != nil || !truncated || len(data) > maxNotifyPayload {
+		t.Fatalf("expected the namespaces to be left out, got %d bytes (%v)", len(data), err)
+	}
+
+	if decoded, _, err := decodeMessage("other", data); err != nil || decoded.ImageID != "alpine" || decoded.Namespaces != nil {
+		t.Errorf("unexpected event: %+v (%v)", decoded, err)
+	}
+
+	event.ImageID = strings.Repeat("a", maxNotifyPayload)
+	if _, _, err := encodeNotification("origin", event); err == nil {
+		t.Error("expected an event exceeding the limit without its namespaces to be rejected")
+	}
+}
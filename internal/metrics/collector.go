@@ -0,0 +1,253 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"github.com/kerezsiz42/scanner-operator2/internal/service"
+)
+
+var (
+	imageVulnerabilities = prometheus.NewDesc(
+		"scanner_image_vulnerabilities",
+		"Number of vulnerabilities found in an image, by the namespaces the image was running in when scanned",
+		[]string{"namespace", "image", "severity"}, nil,
+	)
+	namespaceVulnerabilities = prometheus.NewDesc(
+		"scanner_namespace_vulnerabilities",
+		"Number of vulnerabilities found in the images which were running in a namespace when scanned",
+		[]string{"namespace", "severity"}, nil,
+	)
+	droppedImages = prometheus.NewDesc(
+		"scanner_image_vulnerabilities_dropped_images",
+		"Number of images left out of scanner_image_vulnerabilities to limit its cardinality",
+		nil, nil,
+	)
+	imagesScanned = prometheus.NewDesc(
+		"scanner_images_scanned",
+		"Number of images having a scan result",
+		nil, nil,
+	)
+	vulnerabilityDatabaseAge = prometheus.NewDesc(
+		"scanner_vulnerability_db_age_seconds",
+		"Age of the newest vulnerability database used by the scans in seconds",
+		nil, nil,
+	)
+	reportStorageSavedBytes = prometheus.NewDesc(
+		"scanner_report_storage_saved_bytes",
+		"Bytes saved by storing reports compressed and deduplicated",
+		nil, nil,
+	)
+	reportStorageStoredBytes = prometheus.NewDesc(
+		"scanner_report_storage_stored_bytes",
+		"Bytes of compressed reports stored",
+		nil, nil,
+	)
+)
+
+// ScanResultSource provides the data of the metrics derived from the stored
+// scan results.
+type ScanResultSource interface {
+	ListImageVulnerabilities(ctx context.Context) ([]service.ImageVulnerabilities, error)
+	LatestDatabaseVersion(ctx context.Context) (string, error)
+	ReportStorageStats(ctx context.Context) (*service.ReportStorageStats, error)
+}
+
+// collectTimeout bounds the queries of a scrape to the default scrape timeout
+// of Prometheus, after which their results are not waited for anyway.
+const collectTimeout = 10 * time.Second
+
+// collectCacheTTL is the default scrape interval of Prometheus. The metrics
+// collected within it are served again instead of querying the database on
+// every scrape, e.g. by several Prometheus instances.
+const collectCacheTTL = 30 * time.Second
+
+// scanResultCollector queries the scan results on scrape, so that every
+// replica reports the same values no matter which one received the uploads
+// and deletions. The collected metrics are cached for collectCacheTTL unless
+// a query failed. Only the maxImages most vulnerable images are exported with
+// an image label, the namespace totals include every image.
+type scanResultCollector struct {
+	source    ScanResultSource
+	maxImages int
+	now       func() time.Time
+
+	mu          sync.Mutex
+	collected   []prometheus.Metric
+	collectedAt time.Time
+}
+
+func newScanResultCollector(source ScanResultSource, maxImages int) *scanResultCollector {
+	return &scanResultCollector{
+		source:    source,
+		maxImages: maxImages,
+		now:       time.Now,
+	}
+}
+
+// RegisterScanResultCollector registers the metrics derived from the scan
+// results on the controller-runtime metrics registry.
+func RegisterScanResultCollector(source ScanResultSource, maxImages int) error {
+	return metrics.Registry.Register(newScanResultCollector(source, maxImages))
+}
+
+func (c *scanResultCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- imageVulnerabilities
+	ch <- namespaceVulnerabilities
+	ch <- droppedImages
+	ch <- imagesScanned
+	ch <- vulnerabilityDatabaseAge
+	ch <- reportStorageSavedBytes
+	ch <- reportStorageStoredBytes
+}
+
+func (c *scanResultCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if c.collected == nil || now.Sub(c.collectedAt) >= collectCacheTTL {
+		collected, err := c.collect()
+		c.collected, c.collectedAt = collected, now
+		if err != nil {
+			c.collected = nil
+		}
+
+		for _, metric := range collected {
+			ch <- metric
+		}
+
+		return
+	}
+
+	for _, metric := range c.collected {
+		ch <- metric
+	}
+}
+
+// collect queries the metrics, returning the invalid ones along with the
+// error of a failed query.
+func (c *scanResultCollector) collect() ([]prometheus.Metric, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	ch := make(chan prometheus.Metric)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(ch)
+		errs <- errors.Join(
+			c.collectVulnerabilities(ctx, ch),
+			c.collectDatabaseAge(ctx, ch),
+			c.collectReportStorage(ctx, ch),
+		)
+	}()
+
+	collected := []prometheus.Metric{}
+	for metric := range ch {
+		collected = append(collected, metric)
+	}
+
+	return collected, <-errs
+}
+
+func (c *scanResultCollector) collectVulnerabilities(ctx context.Context, ch chan<- prometheus.Metric) error {
+	rows, err := c.source.ListImageVulnerabilities(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(imageVulnerabilities, err)
+		return err
+	}
+
+	images := map[string]bool{}
+	namespaces := map[string]*database.VulnerabilitySummary{}
+	exported := map[string]bool{}
+	for _, row := range rows {
+		images[row.ImageID] = true
+		if _, ok := namespaces[row.Namespace]; !ok {
+			namespaces[row.Namespace] = &database.VulnerabilitySummary{}
+		}
+
+		addSummary(namespaces[row.Namespace], &row.VulnerabilitySummary)
+
+		if !exported[row.ImageID] && len(exported) >= c.maxImages {
+			continue
+		}
+
+		exported[row.ImageID] = true
+		for severity, count := range severityCounts(&row.VulnerabilitySummary) {
+			ch <- prometheus.MustNewConstMetric(
+				imageVulnerabilities, prometheus.GaugeValue, float64(count),
+				row.Namespace, row.ImageID, severity,
+			)
+		}
+	}
+
+	for namespace, total := range namespaces {
+		for severity, count := range severityCounts(total) {
+			ch <- prometheus.MustNewConstMetric(
+				namespaceVulnerabilities, prometheus.GaugeValue, float64(count),
+				namespace, severity,
+			)
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(droppedImages, prometheus.GaugeValue, float64(len(images)-len(exported)))
+	ch <- prometheus.MustNewConstMetric(imagesScanned, prometheus.GaugeValue, float64(len(images)))
+	return nil
+}
+
+func (c *scanResultCollector) collectDatabaseAge(ctx context.Context, ch chan<- prometheus.Metric) error {
+	version, err := c.source.LatestDatabaseVersion(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(vulnerabilityDatabaseAge, err)
+		return err
+	}
+
+	// The version of the grype database is the time it was built.
+	built, err := time.Parse(time.RFC3339, version)
+	if err != nil {
+		return nil
+	}
+
+	ch <- prometheus.MustNewConstMetric(vulnerabilityDatabaseAge, prometheus.GaugeValue, time.Since(built).Seconds())
+	return nil
+}
+
+func (c *scanResultCollector) collectReportStorage(ctx context.Context, ch chan<- prometheus.Metric) error {
+	stats, err := c.source.ReportStorageStats(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(reportStorageSavedBytes, err)
+		ch <- prometheus.NewInvalidMetric(reportStorageStoredBytes, err)
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(reportStorageSavedBytes, prometheus.GaugeValue, float64(stats.SavedBytes()))
+	ch <- prometheus.MustNewConstMetric(reportStorageStoredBytes, prometheus.GaugeValue, float64(stats.StoredBytes))
+	return nil
+}
+
+// severityCounts maps the severity label values to the counts of a summary.
+func severityCounts(summary *database.VulnerabilitySummary) map[string]int {
+	return map[string]int{
+		"critical": summary.CriticalCount,
+		"high":     summary.HighCount,
+		"medium":   summary.MediumCount,
+		"low":      summary.LowCount,
+		"info":     summary.InfoCount,
+		"unknown":  summary.UnknownCount,
+	}
+}
+
+func addSummary(total *database.VulnerabilitySummary, summary *database.VulnerabilitySummary) {
+	total.CriticalCount += summary.CriticalCount
+	total.HighCount += summary.HighCount
+	total.MediumCount += summary.MediumCount
+	total.LowCount += summary.LowCount
+	total.InfoCount += summary.InfoCount
+	total.UnknownCount += summary.UnknownCount
+}
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"github.com/kerezsiz42/scanner-operator2/internal/service"
+)
+
+type fakeScanResultSource struct {
+	imageVulnerabilities []service.ImageVulnerabilities
+	databaseVersion      string
+	err                  error
+	queries              int
+}
+
+func (f *fakeScanResultSource) ListImageVulnerabilities(_ context.Context) ([]service.ImageVulnerabilities, error) {
+	f.queries++
+	return f.imageVulnerabilities, f.err
+}
+
+func (f *fakeScanResultSource) LatestDatabaseVersion(_ context.Context) (string, error) {
+	return f.databaseVersion, f.err
+}
+
+func (f *fakeScanResultSource) ReportStorageStats(_ context.Context) (*service.ReportStorageStats, error) {
+	return &service.ReportStorageStats{ReferencedBytes: 300, StoredBytes: 100}, f.err
+}
+
+func TestScanResultCollector(t *testing.T) {
+	source := &fakeScanResultSource{
+		imageVulnerabilities: []service.ImageVulnerabilities{
+			{ImageID: "a", Namespace: "default", VulnerabilitySummary: database.VulnerabilitySummary{CriticalCount: 3, HighCount: 1}},
+			{ImageID: "a", Namespace: "kube-system", VulnerabilitySummary: database.VulnerabilitySummary{CriticalCount: 3, HighCount: 1}},
+			{ImageID: "b", Namespace: "default", VulnerabilitySummary: database.VulnerabilitySummary{CriticalCount: 1, LowCount: 2}},
+			{ImageID: "c", Namespace: "", VulnerabilitySummary: database.VulnerabilitySummary{MediumCount: 5}},
+		},
+		databaseVersion: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(newScanResultCollector(source, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP scanner_image_vulnerabilities Number of vulnerabilities found in an image, by the namespaces the image was running in when scanned
+# TYPE scanner_image_vulnerabilities gauge
+scanner_image_vulnerabilities{image="a",namespace="default",severity="critical"} 3
+scanner_image_vulnerabilities{image="a",namespace="default",severity="high"} 1
+scanner_image_vulnerabilities{image="a",namespace="default",severity="info"} 0
+scanner_image_vulnerabilities{image="a",namespace="default",severity="low"} 0
+scanner_image_vulnerabilities{image="a",namespace="default",severity="medium"} 0
+scanner_image_vulnerabilities{image="a",namespace="default",severity="unknown"} 0
+scanner_image_vulnerabilities{image="a",namespace="kube-system",severity="critical"} 3
+scanner_image_vulnerabilities{image="a",namespace="kube-system",severity="high"} 1
+scanner_image_vulnerabilities{image="a",namespace="kube-system",severity="info"} 0
+scanner_image_vulnerabilities{image="a",namespace="kube-system",severity="low"} 0
+scanner_image_vulnerabilities{image="a",namespace="kube-system",severity="medium"} 0
+scanner_image_vulnerabilities{image="a",namespace="kube-system",severity="unknown"} 0
+# HELP scanner_image_vulnerabilities_dropped_images Number of images left out of scanner_image_vulnerabilities to limit its cardinality
+# TYPE scanner_image_vulnerabilities_dropped_images gauge
+scanner_image_vulnerabilities_dropped_images 2
+# HELP scanner_images_scanned Number of images having a scan result
+# TYPE scanner_images_scanned gauge
+scanner_images_scanned 3
+# HELP scanner_namespace_vulnerabilities Number of vulnerabilities found in the images which were running in a namespace when scanned
+# TYPE scanner_namespace_vulnerabilities gauge
+scanner_namespace_vulnerabilities{namespace="",severity="critical"} 0
+scanner_namespace_vulnerabilities{namespace="",severity="high"} 0
+scanner_namespace_vulnerabilities{namespace="",severity="info"} 0
+scanner_namespace_vulnerabilities{namespace="",severity="low"} 0
+scanner_namespace_vulnerabilities{namespace="",severity="medium"} 5
+scanner_namespace_vulnerabilities{namespace="",severity="unknown"} 0
+scanner_namespace_vulnerabilities{namespace="default",severity="critical"} 4
+scanner_namespace_vulnerabilities{namespace="default",severity="high"} 1
+scanner_namespace_vulnerabilities{namespace="default",severity="info"} 0
+scanner_namespace_vulnerabilities{namespace="default",severity="low"} 2
+scanner_namespace_vulnerabilities{namespace="default",severity="medium"} 0
+scanner_namespace_vulnerabilities{namespace="default",severity="unknown"} 0
+scanner_namespace_vulnerabilities{namespace="kube-system",severity="critical"} 3
+scanner_namespace_vulnerabilities{namespace="kube-system",severity="high"} 1
+scanner_namespace_vulnerabilities{namespace="kube-system",severity="info"} 0
+scanner_namespace_vulnerabilities{namespace="kube-system",severity="low"} 0
+scanner_namespace_vulnerabilities{namespace="kube-system",severity="medium"} 0
+scanner_namespace_vulnerabilities{namespace="kube-system",severity="unknown"} 0
+# HELP scanner_report_storage_saved_bytes Bytes saved by storing reports compressed and deduplicated
+# TYPE scanner_report_storage_saved_bytes gauge
+scanner_report_storage_saved_bytes 200
+# HELP scanner_report_storage_stored_bytes Bytes of compressed reports stored
+# TYPE scanner_report_storage_stored_bytes gauge
+scanner_report_storage_stored_bytes 100
+`
+	names := []string{
+		"scanner_image_vulnerabilities",
+		"scanner_image_vulnerabilities_dropped_images",
+		"scanner_images_scanned",
+		"scanner_namespace_vulnerabilities",
+		"scanner_report_storage_saved_bytes",
+		"scanner_report_storage_stored_bytes",
+	}
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), names...); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != "scanner_vulnerability_db_age_seconds" {
+			continue
+		}
+
+		age := family.GetMetric()[0].GetGauge().GetValue()
+		if age < time.Hour.Seconds() || age > 2*time.Hour.Seconds() {
+			t.Fatalf("unexpected vulnerability database age: %v", age)
+		}
+
+		return
+	}
+
+	t.Fatal("scanner_vulnerability_db_age_seconds is missing")
+}
+
+func TestScanResultCollectorError(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	source := &fakeScanResultSource{err: errors.New("database is down")}
+	if err := registry.Register(newScanResultCollector(source, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := registry.Gather(); err == nil {
+		t.Fatal("expected the scrape to fail")
+	}
+}
+
+func TestScanResultCollectorCaches(t *testing.T) {
+	source := &fakeScanResultSource{err: errors.New("database is down")}
+	c := newScanResultCollector(source, 1)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(c); err != nil {
+		t.Fatal(err)
+	}
+
+	gather := func(expected int) {
+		t.Helper()
+		_, _ = registry.Gather()
+		if source.queries != expected {
+			t.Fatalf("expected %d queries, got %d", expected, source.queries)
+		}
+	}
+
+	// The failed scrapes are not cached.
+	gather(1)
+	gather(2)
+
+	source.err = nil
+	gather(3)
+	gather(3)
+
+	now = now.Add(collectCacheTTL)
+	gather(4)
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	ImagesScannedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "scanner_images_scanned_total",
+			Help: "Number of reports uploaded",
+		},
+	)
+	ImagesPending = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scanner_images_pending",
+			Help: "Number of images running in the namespace of a Scanner which are not scanned yet",
+		},
+		[]string{"namespace"},
+	)
+	ScanDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "scanner_scan_duration_seconds",
+			Help:    "Time elapsed between the creation of a scan Job and the upload of its report in seconds",
+			Buckets: prometheus.ExponentialBuckets(5, 2, 10),
+		},
+	)
+	ScanFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scanner_scan_failures_total",
+			Help: "Number of scans which failed, by reason",
+		},
+		[]string{"reason"},
+	)
+)
+
+// Reasons of scan failures.
+const (
+	JobFailed     = "job_failed"
+	InvalidReport = "invalid_report"
+	UploadFailed  = "upload_failed"
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		ImagesScannedTotal,
+		ImagesPending,
+		ScanDuration,
+		ScanFailuresTotal,
+	)
+}
@@ -9,18 +9,362 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/oapi-codegen/runtime"
 )
 
+// Defines values for EventType.
+const (
+	ScanFailed        EventType = "scanFailed"
+	ScanResultCreated EventType = "scanResultCreated"
+	ScanResultDeleted EventType = "scanResultDeleted"
+	ScanResultUpdated EventType = "scanResultUpdated"
+	ScanStarted       EventType = "scanStarted"
+)
+
+// Defines values for Severity.
+const (
+	Critical Severity = "critical"
+	High     Severity = "high"
+	Info     Severity = "info"
+	Low      Severity = "low"
+	Medium   Severity = "medium"
+	None     Severity = "none"
+)
+
+// Defines values for GetScanResultsParamsSort.
+const (
+	CreatedAt GetScanResultsParamsSort = "createdAt"
+	ImageId   GetScanResultsParamsSort = "imageId"
+	UpdatedAt GetScanResultsParamsSort = "updatedAt"
+)
+
+// Defines values for GetScanResultsParamsOrder.
+const (
+	Asc  GetScanResultsParamsOrder = "asc"
+	Desc GetScanResultsParamsOrder = "desc"
+)
+
+// Defines values for GetScanResultsParamsView.
+const (
+	Full    GetScanResultsParamsView = "full"
+	Summary GetScanResultsParamsView = "summary"
+)
+
+// Defines values for GetSubscribeParamsFormat.
+const (
+	Envelope GetSubscribeParamsFormat = "envelope"
+	Legacy   GetSubscribeParamsFormat = "legacy"
+)
+
+// Event is a change of a ScanResult or of a scan sent on the /subscribe websocket.
+type Event struct {
+	ImageId string `json:"imageId"`
+
+	// Namespaces are the namespaces in which the image was running when it was scanned.
+	Namespaces []string `json:"namespaces"`
+
+	// Sequence increases by one with every Event sent by the server.
+	Sequence int64 `json:"sequence"`
+
+	// Summary counts the findings of the report by severity.
+	Summary   *VulnerabilitySummary `json:"summary,omitempty"`
+	Timestamp time.Time             `json:"timestamp"`
+	Type      EventType             `json:"type"`
+
+	// Version is the version of the envelope, increased on incompatible changes.
+	Version int `json:"version"`
+}
+
+// EventFilter selects the Events a subscriber receives. Omitted or empty fields match every Event.
+type EventFilter struct {
+	// MinSeverity Only Events of images with at least one vulnerability of this severity or higher. Events
+	// without a summary are not filtered by severity.
+	MinSeverity *Severity `json:"minSeverity,omitempty"`
+
+	// Namespaces Only Events of images running in one of the namespaces.
+	Namespaces *[]string `json:"namespaces,omitempty"`
+
+	// Repositories Only Events of images pulled by digest from one of the repositories.
+	Repositories *[]string    `json:"repositories,omitempty"`
+	Types        *[]EventType `json:"types,omitempty"`
+}
+
+// EventType defines model for EventType.
+type EventType string
+
+// Finding defines model for Finding.
+type Finding struct {
+	Aliases []string `json:"aliases"`
+
+	// PreviousSeverity is the severity of the finding in the scan compared against, if it changed.
+	PreviousSeverity *string `json:"previousSeverity,omitempty"`
+
+	// Purl is the package URL of the affected component.
+	Purl            string `json:"purl"`
+	Severity        string `json:"severity"`
+	VulnerabilityId string `json:"vulnerabilityId"`
+}
+
+// ImageMatch defines model for ImageMatch.
+type ImageMatch struct {
+	ImageId string `json:"imageId"`
+
+	// Purls are the package URLs of the matching components in the image.
+	Purls []string `json:"purls"`
+
+	// Severity is the highest severity of the vulnerability in the image, if a vulnerability was searched for.
+	Severity *string `json:"severity,omitempty"`
+
+	// Workloads are the containers currently running the image.
+	Workloads []Workload `json:"workloads"`
+}
+
+// Problem describes why a request failed, as defined by RFC 7807. Clients tell the errors apart by
+// their type, the title and the detail are meant for humans.
+type Problem struct {
+	// Detail explains this occurrence of the error.
+	Detail *string `json:"detail,omitempty"`
+
+	// Instance is the path of the request which failed.
+	Instance *string `json:"instance,omitempty"`
+
+	// Status is the HTTP status code of the response.
+	Status int `json:"status"`
+
+	// Title is a short summary of the kind of error, which does not change between occurrences.
+	Title string `json:"title"`
+
+	// Type identifies the kind of error.
+	Type string `json:"type"`
+}
+
+// Scan defines model for Scan.
+type Scan struct {
+	CreatedAt time.Time `json:"createdAt"`
+
+	// DatabaseVersion is the version of the vulnerability database used by the scanner, if known.
+	DatabaseVersion     string  `json:"databaseVersion"`
+	Id                  int64   `json:"id"`
+	ImageId             string  `json:"imageId"`
+	ScanDurationSeconds float64 `json:"scanDurationSeconds"`
+	ScannerName         string  `json:"scannerName"`
+	ScannerVersion      string  `json:"scannerVersion"`
+}
+
+// ScanDiff defines model for ScanDiff.
+type ScanDiff struct {
+	Fixed           []Finding `json:"fixed"`
+	From            *Scan     `json:"from,omitempty"`
+	Introduced      []Finding `json:"introduced"`
+	SeverityChanged []Finding `json:"severityChanged"`
+	To              Scan      `json:"to"`
+}
+
 // ScanResult defines model for ScanResult.
 type ScanResult struct {
-	ImageId string `json:"imageId"`
+	// CreatedAt is the time the image was first scanned.
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+
+	// DatabaseVersion is the version of the vulnerability database used by the scanner.
+	DatabaseVersion *string `json:"databaseVersion,omitempty"`
+	ImageId         string  `json:"imageId"`
+
+	// Report is a big JSON object which should conform to the CycloneDX BOM schema. It is required when uploading and left out of the summary view.
+	Report *json.RawMessage `json:"report,omitempty"`
+
+	// ScanDurationSeconds is the time elapsed between the creation of the scan Job and the upload of the report.
+	ScanDurationSeconds *float64 `json:"scanDurationSeconds,omitempty"`
+
+	// ScannerName defaults to the tool recorded in the metadata of the report if neither scannerName nor scannerVersion is set.
+	ScannerName    *string `json:"scannerName,omitempty"`
+	ScannerVersion *string `json:"scannerVersion,omitempty"`
+
+	// Summary counts the findings of the report by severity.
+	Summary *VulnerabilitySummary `json:"summary,omitempty"`
+
+	// TriggeredBy are the containers which were running the image when the scan was started.
+	TriggeredBy *[]ScanTrigger `json:"triggeredBy,omitempty"`
+
+	// UpdatedAt is the time the latest report of the image was uploaded.
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// ScanTrigger defines model for ScanTrigger.
+type ScanTrigger struct {
+	Container string `json:"container"`
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+}
+
+// Severity Only Events of images with at least one vulnerability of this severity or higher. Events
+// without a summary are not filtered by severity.
+type Severity string
+
+// VulnerabilitySummary counts the findings of the report by severity.
+type VulnerabilitySummary struct {
+	// Components is the number of components in the report.
+	Components int `json:"components"`
+	Critical   int `json:"critical"`
+
+	// Fixable is the number of findings for which the scanner recommends an upgrade.
+	Fixable int `json:"fixable"`
+	High    int `json:"high"`
+
+	// Info includes the findings of severity none.
+	Info int `json:"info"`
+	Low  int `json:"low"`
+
+	// MaxCvssScore is the highest CVSS score of the vulnerabilities, 0 if none is rated.
+	MaxCvssScore float64 `json:"maxCvssScore"`
+	Medium       int     `json:"medium"`
+	Unknown      int     `json:"unknown"`
+}
+
+// Workload defines model for Workload.
+type Workload struct {
+	Container string `json:"container"`
+
+	// Kind is the kind of the top-level controller of the pod, e.g. Deployment.
+	Kind *string `json:"kind,omitempty"`
+
+	// Name is the name of the top-level controller of the pod.
+	Name      *string `json:"name,omitempty"`
+	Namespace string  `json:"namespace"`
+	Pod       string  `json:"pod"`
+}
+
+// EventMinSeverity Only Events of images with at least one vulnerability of this severity or higher. Events
+// without a summary are not filtered by severity.
+type EventMinSeverity = Severity
+
+// EventNamespaces defines model for EventNamespaces.
+type EventNamespaces = []string
+
+// EventRepositories defines model for EventRepositories.
+type EventRepositories = []string
+
+// EventTypes defines model for EventTypes.
+type EventTypes = []EventType
+
+// BadRequest describes why a request failed, as defined by RFC 7807. Clients tell the errors apart by
+// their type, the title and the detail are meant for humans.
+type BadRequest = Problem
 
-	// Report is a big JSON object which should conform to the CycloneDX BOM schema.
-	Report json.RawMessage `json:"report"`
+// InternalServerError describes why a request failed, as defined by RFC 7807. Clients tell the errors apart by
+// their type, the title and the detail are meant for humans.
+type InternalServerError = Problem
+
+// NotFound describes why a request failed, as defined by RFC 7807. Clients tell the errors apart by
+// their type, the title and the detail are meant for humans.
+type NotFound = Problem
+
+// ServiceUnavailable describes why a request failed, as defined by RFC 7807. Clients tell the errors apart by
+// their type, the title and the detail are meant for humans.
+type ServiceUnavailable = Problem
+
+// Unauthorized describes why a request failed, as defined by RFC 7807. Clients tell the errors apart by
+// their type, the title and the detail are meant for humans.
+type Unauthorized = Problem
+
+// GetComponentsParams defines parameters for GetComponents.
+type GetComponentsParams struct {
+	// Purl Package URL to look for. Without a version every version of the package matches.
+	Purl string `form:"purl" json:"purl"`
 }
 
+// GetEventsParams defines parameters for GetEvents.
+type GetEventsParams struct {
+	// Namespace Only Events of images running in one of the namespaces.
+	Namespace *EventNamespaces `form:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// Repository Only Events of images pulled by digest from one of the repositories.
+	Repository  *EventRepositories `form:"repository,omitempty" json:"repository,omitempty"`
+	MinSeverity *EventMinSeverity  `form:"minSeverity,omitempty" json:"minSeverity,omitempty"`
+
+	// Type Only Events of these types.
+	Type *EventTypes `form:"type,omitempty" json:"type,omitempty"`
+
+	// LastEventID is the id of the last message received. The Events published since then are sent
+	// first if the server still has them, otherwise a reset event is sent, e.g. when the id
+	// was sent by another replica or before the server was restarted.
+	LastEventID *string `json:"Last-Event-ID,omitempty"`
+}
+
+// GetScanResultsParams defines parameters for GetScanResults.
+type GetScanResultsParams struct {
+	// Limit Maximum number of ScanResults in the response.
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor Continues the listing after the last ScanResult of the previous page, as returned in the X-Next-Cursor header.
+	Cursor *string `form:"cursor,omitempty" json:"cursor,omitempty"`
+
+	// Namespace Only ScanResults of images which were running in the namespace when they were scanned.
+	Namespace *string `form:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// MinSeverity Only ScanResults with at least one vulnerability of this severity or higher.
+	MinSeverity *Severity `form:"minSeverity,omitempty" json:"minSeverity,omitempty"`
+
+	// HasFix Only ScanResults with (true) or without (false) a vulnerability which has a fix available.
+	HasFix *bool `form:"hasFix,omitempty" json:"hasFix,omitempty"`
+
+	// Repository Only ScanResults of images from the repository, e.g. docker.io/library/alpine.
+	Repository *string `form:"repository,omitempty" json:"repository,omitempty"`
+
+	// ScannedSince Only ScanResults whose latest report was uploaded at or after this time.
+	ScannedSince *time.Time                 `form:"scannedSince,omitempty" json:"scannedSince,omitempty"`
+	Sort         *GetScanResultsParamsSort  `form:"sort,omitempty" json:"sort,omitempty"`
+	Order        *GetScanResultsParamsOrder `form:"order,omitempty" json:"order,omitempty"`
+
+	// View The summary view leaves out the reports.
+	View *GetScanResultsParamsView `form:"view,omitempty" json:"view,omitempty"`
+}
+
+// GetScanResultsParamsSort defines parameters for GetScanResults.
+type GetScanResultsParamsSort string
+
+// GetScanResultsParamsOrder defines parameters for GetScanResults.
+type GetScanResultsParamsOrder string
+
+// GetScanResultsParamsView defines parameters for GetScanResults.
+type GetScanResultsParamsView string
+
+// PutScanResultsParams defines parameters for PutScanResults.
+type PutScanResultsParams struct {
+	// XUploadToken is the one-time token of the scan Job, which is only accepted for the image it was issued for.
+	XUploadToken *string `json:"X-Upload-Token,omitempty"`
+}
+
+// GetScanResultsImageIdDiffParams defines parameters for GetScanResultsImageIdDiff.
+type GetScanResultsImageIdDiffParams struct {
+	// From ID of the scan to compare against. Defaults to the scan preceding the target scan.
+	From *int64 `form:"from,omitempty" json:"from,omitempty"`
+
+	// To ID of the target scan. Defaults to the latest scan of the image.
+	To *int64 `form:"to,omitempty" json:"to,omitempty"`
+}
+
+// GetSubscribeParams defines parameters for GetSubscribe.
+type GetSubscribeParams struct {
+	// Format selects the format of the messages. The envelope format sends every change as an Event,
+	// the legacy format only sends the quoted imageIds of the inserted and updated ScanResults.
+	Format *GetSubscribeParamsFormat `form:"format,omitempty" json:"format,omitempty"`
+
+	// Namespace Only Events of images running in one of the namespaces.
+	Namespace *EventNamespaces `form:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// Repository Only Events of images pulled by digest from one of the repositories.
+	Repository  *EventRepositories `form:"repository,omitempty" json:"repository,omitempty"`
+	MinSeverity *EventMinSeverity  `form:"minSeverity,omitempty" json:"minSeverity,omitempty"`
+
+	// Type Only Events of these types.
+	Type *EventTypes `form:"type,omitempty" json:"type,omitempty"`
+}
+
+// GetSubscribeParamsFormat defines parameters for GetSubscribe.
+type GetSubscribeParamsFormat string
+
 // PutScanResultsJSONRequestBody defines body for PutScanResults for application/json ContentType.
 type PutScanResultsJSONRequestBody = ScanResult
 
@@ -33,14 +377,20 @@ type ServerInterface interface {
 	// (GET /bundle.js)
 	GetBundleJs(w http.ResponseWriter, r *http.Request)
 
+	// (GET /components)
+	GetComponents(w http.ResponseWriter, r *http.Request, params GetComponentsParams)
+
+	// (GET /events)
+	GetEvents(w http.ResponseWriter, r *http.Request, params GetEventsParams)
+
 	// (GET /output.css)
 	GetOutputCss(w http.ResponseWriter, r *http.Request)
 
 	// (GET /scan-results)
-	GetScanResults(w http.ResponseWriter, r *http.Request)
+	GetScanResults(w http.ResponseWriter, r *http.Request, params GetScanResultsParams)
 
 	// (PUT /scan-results)
-	PutScanResults(w http.ResponseWriter, r *http.Request)
+	PutScanResults(w http.ResponseWriter, r *http.Request, params PutScanResultsParams)
 
 	// (DELETE /scan-results/{imageId})
 	DeleteScanResultsImageId(w http.ResponseWriter, r *http.Request, imageId string)
@@ -48,8 +398,17 @@ type ServerInterface interface {
 	// (GET /scan-results/{imageId})
 	GetScanResultsImageId(w http.ResponseWriter, r *http.Request, imageId string)
 
+	// (GET /scan-results/{imageId}/diff)
+	GetScanResultsImageIdDiff(w http.ResponseWriter, r *http.Request, imageId string, params GetScanResultsImageIdDiffParams)
+
+	// (GET /scan-results/{imageId}/scans)
+	GetScanResultsImageIdScans(w http.ResponseWriter, r *http.Request, imageId string)
+
 	// (GET /subscribe)
-	GetSubscribe(w http.ResponseWriter, r *http.Request)
+	GetSubscribe(w http.ResponseWriter, r *http.Request, params GetSubscribeParams)
+
+	// (GET /vulnerabilities/{vulnerabilityId}/images)
+	GetVulnerabilitiesVulnerabilityIdImages(w http.ResponseWriter, r *http.Request, vulnerabilityId string)
 }
 
 // ServerInterfaceWrapper converts contexts to parameters.
@@ -89,6 +448,112 @@ func (siw *ServerInterfaceWrapper) GetBundleJs(w http.ResponseWriter, r *http.Re
 	handler.ServeHTTP(w, r)
 }
 
+// GetComponents operation middleware
+func (siw *ServerInterfaceWrapper) GetComponents(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetComponentsParams
+
+	// ------------- Required query parameter "purl" -------------
+
+	if paramValue := r.URL.Query().Get("purl"); paramValue != "" {
+
+	} else {
+		siw.ErrorHandlerFunc(w, r, &RequiredParamError{ParamName: "purl"})
+		return
+	}
+
+	err = runtime.BindQueryParameter("form", true, true, "purl", r.URL.Query(), &params.Purl)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "purl", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetComponents(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetEvents operation middleware
+func (siw *ServerInterfaceWrapper) GetEvents(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetEventsParams
+
+	// ------------- Optional query parameter "namespace" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "namespace", r.URL.Query(), &params.Namespace)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "namespace", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "repository" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "repository", r.URL.Query(), &params.Repository)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "repository", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "minSeverity" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "minSeverity", r.URL.Query(), &params.MinSeverity)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "minSeverity", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "type" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "type", r.URL.Query(), &params.Type)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "type", Err: err})
+		return
+	}
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Last-Event-ID" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Last-Event-ID")]; found {
+		var LastEventID string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Last-Event-ID", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Last-Event-ID", valueList[0], &LastEventID, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Last-Event-ID", Err: err})
+			return
+		}
+
+		params.LastEventID = &LastEventID
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetEvents(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
 // GetOutputCss operation middleware
 func (siw *ServerInterfaceWrapper) GetOutputCss(w http.ResponseWriter, r *http.Request) {
 
@@ -106,8 +571,93 @@ func (siw *ServerInterfaceWrapper) GetOutputCss(w http.ResponseWriter, r *http.R
 // GetScanResults operation middleware
 func (siw *ServerInterfaceWrapper) GetScanResults(w http.ResponseWriter, r *http.Request) {
 
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetScanResultsParams
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", r.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "cursor", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "namespace" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "namespace", r.URL.Query(), &params.Namespace)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "namespace", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "minSeverity" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "minSeverity", r.URL.Query(), &params.MinSeverity)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "minSeverity", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "hasFix" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "hasFix", r.URL.Query(), &params.HasFix)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "hasFix", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "repository" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "repository", r.URL.Query(), &params.Repository)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "repository", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "scannedSince" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "scannedSince", r.URL.Query(), &params.ScannedSince)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "scannedSince", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "sort" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "sort", r.URL.Query(), &params.Sort)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "sort", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "order" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "order", r.URL.Query(), &params.Order)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "order", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "view" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "view", r.URL.Query(), &params.View)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "view", Err: err})
+		return
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetScanResults(w, r)
+		siw.Handler.GetScanResults(w, r, params)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -120,8 +670,34 @@ func (siw *ServerInterfaceWrapper) GetScanResults(w http.ResponseWriter, r *http
 // PutScanResults operation middleware
 func (siw *ServerInterfaceWrapper) PutScanResults(w http.ResponseWriter, r *http.Request) {
 
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params PutScanResultsParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "X-Upload-Token" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("X-Upload-Token")]; found {
+		var XUploadToken string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "X-Upload-Token", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "X-Upload-Token", valueList[0], &XUploadToken, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "X-Upload-Token", Err: err})
+			return
+		}
+
+		params.XUploadToken = &XUploadToken
+
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.PutScanResults(w, r)
+		siw.Handler.PutScanResults(w, r, params)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -181,11 +757,150 @@ func (siw *ServerInterfaceWrapper) GetScanResultsImageId(w http.ResponseWriter,
 	handler.ServeHTTP(w, r)
 }
 
+// GetScanResultsImageIdDiff operation middleware
+func (siw *ServerInterfaceWrapper) GetScanResultsImageIdDiff(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "imageId" -------------
+	var imageId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "imageId", r.PathValue("imageId"), &imageId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "imageId", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetScanResultsImageIdDiffParams
+
+	// ------------- Optional query parameter "from" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "from", r.URL.Query(), &params.From)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "from", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "to" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "to", r.URL.Query(), &params.To)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "to", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetScanResultsImageIdDiff(w, r, imageId, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetScanResultsImageIdScans operation middleware
+func (siw *ServerInterfaceWrapper) GetScanResultsImageIdScans(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "imageId" -------------
+	var imageId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "imageId", r.PathValue("imageId"), &imageId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "imageId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetScanResultsImageIdScans(w, r, imageId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
 // GetSubscribe operation middleware
 func (siw *ServerInterfaceWrapper) GetSubscribe(w http.ResponseWriter, r *http.Request) {
 
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetSubscribeParams
+
+	// ------------- Optional query parameter "format" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "format", r.URL.Query(), &params.Format)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "format", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "namespace" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "namespace", r.URL.Query(), &params.Namespace)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "namespace", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "repository" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "repository", r.URL.Query(), &params.Repository)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "repository", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "minSeverity" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "minSeverity", r.URL.Query(), &params.MinSeverity)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "minSeverity", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "type" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "type", r.URL.Query(), &params.Type)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "type", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetSubscribe(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetVulnerabilitiesVulnerabilityIdImages operation middleware
+func (siw *ServerInterfaceWrapper) GetVulnerabilitiesVulnerabilityIdImages(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "vulnerabilityId" -------------
+	var vulnerabilityId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "vulnerabilityId", r.PathValue("vulnerabilityId"), &vulnerabilityId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "vulnerabilityId", Err: err})
+		return
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetSubscribe(w, r)
+		siw.Handler.GetVulnerabilitiesVulnerabilityIdImages(w, r, vulnerabilityId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -317,12 +1032,17 @@ func HandlerWithOptions(si ServerInterface, options StdHTTPServerOptions) http.H
 
 	m.HandleFunc("GET "+options.BaseURL+"/", wrapper.Get)
 	m.HandleFunc("GET "+options.BaseURL+"/bundle.js", wrapper.GetBundleJs)
+	m.HandleFunc("GET "+options.BaseURL+"/components", wrapper.GetComponents)
+	m.HandleFunc("GET "+options.BaseURL+"/events", wrapper.GetEvents)
 	m.HandleFunc("GET "+options.BaseURL+"/output.css", wrapper.GetOutputCss)
 	m.HandleFunc("GET "+options.BaseURL+"/scan-results", wrapper.GetScanResults)
 	m.HandleFunc("PUT "+options.BaseURL+"/scan-results", wrapper.PutScanResults)
 	m.HandleFunc("DELETE "+options.BaseURL+"/scan-results/{imageId}", wrapper.DeleteScanResultsImageId)
 	m.HandleFunc("GET "+options.BaseURL+"/scan-results/{imageId}", wrapper.GetScanResultsImageId)
+	m.HandleFunc("GET "+options.BaseURL+"/scan-results/{imageId}/diff", wrapper.GetScanResultsImageIdDiff)
+	m.HandleFunc("GET "+options.BaseURL+"/scan-results/{imageId}/scans", wrapper.GetScanResultsImageIdScans)
 	m.HandleFunc("GET "+options.BaseURL+"/subscribe", wrapper.GetSubscribe)
+	m.HandleFunc("GET "+options.BaseURL+"/vulnerabilities/{vulnerabilityId}/images", wrapper.GetVulnerabilitiesVulnerabilityIdImages)
 
 	return m
 }
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/authenticatorfactory"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/authorization/authorizerfactory"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	authenticationv1 "k8s.io/client-go/kubernetes/typed/authentication/v1"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/rest"
+)
+
+// The API is authorized as if the ScanResults were a resource of the
+// Kubernetes API, so access is granted with ordinary RBAC rules.
+const (
+	apiGroup           = "scanner.zoltankerezsi.xyz"
+	scanResultResource = "scanresults"
+)
+
+// publicPaths are served without authentication, as the frontend is loaded by
+// browsers before they could authenticate.
+var publicPaths = map[string]bool{
+	"/":           true,
+	"/bundle.js":  true,
+	"/output.css": true,
+}
+
+// NewDelegatingAuth returns an authenticator of the bearer tokens using
+// TokenReviews and an authorizer using SubjectAccessReviews, like
+// filters.WithAuthenticationAndAuthorization does for the metrics endpoint.
+func NewDelegatingAuth(config *rest.Config, httpClient *http.Client) (authenticator.Request, authorizer.Authorizer, error) {
+	authenticationV1Client, err := authenticationv1.NewForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authorizationV1Client, err := authorizationv1.NewForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	backoff := &wait.Backoff{
+		Duration: 500 * time.Millisecond,
+		Factor:   1.5,
+		Jitter:   0.2,
+		Steps:    5,
+	}
+
+	authenticatorConfig := authenticatorfactory.DelegatingAuthenticatorConfig{
+		Anonymous:                false,
+		CacheTTL:                 1 * time.Minute,
+		TokenAccessReviewClient:  authenticationV1Client,
+		TokenAccessReviewTimeout: 10 * time.Second,
+		WebhookRetryBackoff:      backoff,
+	}
+	delegatingAuthenticator, _, err := authenticatorConfig.New()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create authenticator: %w", err)
+	}
+
+	authorizerConfig := authorizerfactory.DelegatingAuthorizerConfig{
+		SubjectAccessReviewClient: authorizationV1Client,
+		AllowCacheTTL:             5 * time.Minute,
+		DenyCacheTTL:              30 * time.Second,
+		WebhookRetryBackoff:       backoff,
+	}
+	delegatingAuthorizer, err := authorizerConfig.New()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create authorizer: %w", err)
+	}
+
+	return delegatingAuthenticator, delegatingAuthorizer, nil
+}
+
+// WithAuth lets the authenticated and authorized requests through to the
+// handler with the user stored in their context.
+func WithAuth(authn authenticator.Request, authz authorizer.Authorizer, logger logr.Logger, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && publicPaths[r.URL.Path] {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		res, ok, err := authn.AuthenticateRequest(r)
+		if err != nil {
+			logger.Error(err, "Authentication failed")
+			writeProblem(w, r, unauthorized, "the credentials could not be verified")
+			return
+		}
+
+		if !ok {
+			writeProblem(w, r, unauthorized, "a bearer token is required")
+			return
+		}
+
+		attributes := requestAttributes(r)
+		attributes.User = res.User
+		// The scan Jobs run with a service account of the namespace they scan,
+		// whose permissions are granted in that namespace only.
+		if attributes.Verb == "update" {
+			if namespace, _, err := serviceaccount.SplitUsername(res.User.GetName()); err == nil {
+				attributes.Namespace = namespace
+			}
+		}
+
+		decision, reason, err := authz.Authorize(r.Context(), attributes)
+		if err != nil {
+			logger.Error(err, "Authorization failed", "user", res.User.GetName())
+			writeProblem(w, r, internalError, "")
+			return
+		}
+
+		if decision != authorizer.DecisionAllow {
+			logger.V(4).Info("Authorization denied", "user", res.User.GetName(), "verb", attributes.Verb, "reason", reason)
+			detail := fmt.Sprintf("%s can not %s %s.%s", res.User.GetName(), attributes.Verb, attributes.Resource, attributes.APIGroup)
+			writeProblem(w, r, forbidden, detail)
+			return
+		}
+
+		handler.ServeHTTP(w, r.WithContext(request.WithUser(r.Context(), res.User)))
+	})
+}
+
+// requestAttributes maps the request to a verb on the scanresults resource.
+func requestAttributes(r *http.Request) authorizer.AttributesRecord {
+	attributes := authorizer.AttributesRecord{
+		APIGroup:        apiGroup,
+		Resource:        scanResultResource,
+		ResourceRequest: true,
+		Path:            r.URL.Path,
+	}
+
+	switch {
+	case r.Method == http.MethodPut:
+		attributes.Verb = "update"
+	case r.Method == http.MethodDelete:
+		attributes.Verb = "delete"
+	case r.URL.Path == "/subscribe" || r.URL.Path == "/events":
+		attributes.Verb = "watch"
+	case r.URL.Path == "/scan-results" || r.URL.Path == "/components" || strings.HasPrefix(r.URL.Path, "/vulnerabilities/"):
+		attributes.Verb = "list"
+	default:
+		attributes.Verb = "get"
+	}
+
+	return attributes
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// fakeAuthenticator authenticates the requests with a bearer token being the
+// name of the user.
+type fakeAuthenticator struct{}
+
+func (fakeAuthenticator) AuthenticateRequest(r *http.Request) (*authenticator.Response, bool, error) {
+	name := r.Header.Get("Authorization")
+	if name == "" {
+		return nil, false, nil
+	}
+
+	return &authenticator.Response{User: &user.DefaultInfo{Name: name}}, true, nil
+}
+
+// fakeAuthorizer allows the verbs listed for the users, recording the last
+// attributes authorized.
+type fakeAuthorizer struct {
+	verbs      map[string][]string
+	attributes authorizer.Attributes
+}
+
+func (a *fakeAuthorizer) Authorize(ctx context.Context, attributes authorizer.Attributes) (authorizer.Decision, string, error) {
+	a.attributes = attributes
+	for _, verb := range a.verbs[attributes.GetUser().GetName()] {
+		if verb == attributes.GetVerb() {
+			return authorizer.DecisionAllow, "", nil
+		}
+	}
+
+	return authorizer.DecisionNoOpinion, "", nil
+}
+
+func TestWithAuth(t *testing.T) {
+	const scanJob = "system:serviceaccount:team-a:scanner-job"
+	authz := &fakeAuthorizer{verbs: map[string][]string{
+		"viewer": {"get", "list", "watch"},
+		scanJob:  {"update"},
+	}}
+
+	handler := WithAuth(fakeAuthenticator{}, authz, logr.Discard(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := request.UserFrom(r.Context()); !ok && !publicPaths[r.URL.Path] {
+			t.Errorf("the user is missing from the context of %s", r.URL.Path)
+		}
+	}))
+
+	cases := []struct {
+		method string
+		path   string
+		user   string
+		status int
+		verb   string
+	}{
+		{http.MethodGet, "/", "", http.StatusOK, ""},
+		{http.MethodGet, "/bundle.js", "", http.StatusOK, ""},
+		{http.MethodGet, "/scan-results", "", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/scan-results", "viewer", http.StatusOK, "list"},
+		{http.MethodGet, "/scan-results/alpine", "viewer", http.StatusOK, "get"},
+		{http.MethodGet, "/vulnerabilities/CVE-2024-1", "viewer", http.StatusOK, "list"},
+		{http.MethodGet, "/subscribe", "viewer", http.StatusOK, "watch"},
+		{http.MethodGet, "/events", "viewer", http.StatusOK, "watch"},
+		{http.MethodPut, "/scan-results", "viewer", http.StatusForbidden, "update"},
+		{http.MethodDelete, "/scan-results/alpine", "viewer", http.StatusForbidden, "delete"},
+		{http.MethodPut, "/scan-results", scanJob, http.StatusOK, "update"},
+		{http.MethodGet, "/scan-results", scanJob, http.StatusForbidden, "list"},
+	}
+
+	for _, c := range cases {
+		authz.attributes = nil
+		r := httptest.NewRequest(c.method, c.path, nil)
+		if c.user != "" {
+			r.Header.Set("Authorization", c.user)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("%s %s as %q: expected %d, got %d", c.method, c.path, c.user, c.status, w.Code)
+		}
+
+		if c.verb != "" && (authz.attributes == nil || authz.attributes.GetVerb() != c.verb ||
+			authz.attributes.GetResource() != scanResultResource || authz.attributes.GetAPIGroup() != apiGroup) {
+			t.Errorf("%s %s: unexpected attributes %+v", c.method, c.path, authz.attributes)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPut, "/scan-results", nil)
+	r.Header.Set("Authorization", scanJob)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if authz.attributes.GetNamespace() != "team-a" {
+		t.Errorf("expected the upload to be authorized in the namespace of the job, got %q", authz.attributes.GetNamespace())
+	}
+}
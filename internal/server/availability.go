@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+)
+
+// WithDatabaseAvailability responds 503 Service Unavailable while the database
+// is unavailable, telling the clients when to retry instead of failing every
+// query with 500. The frontend is still served, as it does not need the
+// database.
+func WithDatabaseAvailability(availability database.Availability, retryAfter time.Duration, handler http.Handler) http.Handler {
+	retryAfterSeconds := strconv.Itoa(max(int(retryAfter.Seconds()), 1))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if availability.Available() || (r.Method == http.MethodGet && publicPaths[r.URL.Path]) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", retryAfterSeconds)
+		writeProblem(w, r, unavailable, "the database is unavailable")
+	})
+}
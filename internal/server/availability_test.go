@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeAvailability bool
+
+func (a fakeAvailability) Available() bool {
+	return bool(a)
+}
+
+func TestWithDatabaseAvailability(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, tc := range []struct {
+		name         string
+		available    bool
+		path         string
+		expectedCode int
+	}{
+		{name: "available", available: true, path: "/scan-results", expectedCode: http.StatusOK},
+		{name: "unavailable", available: false, path: "/scan-results", expectedCode: http.StatusServiceUnavailable},
+		{name: "frontend", available: false, path: "/bundle.js", expectedCode: http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := WithDatabaseAvailability(fakeAvailability(tc.available), 5*time.Second, ok)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if w.Code != tc.expectedCode {
+				t.Errorf("expected %d, got %d", tc.expectedCode, w.Code)
+			}
+
+			if tc.expectedCode == http.StatusServiceUnavailable && w.Header().Get("Retry-After") != "5" {
+				t.Errorf("expected Retry-After of 5 seconds, got %q", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
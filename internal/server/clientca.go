@@ -0,0 +1,110 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// clientCAPool holds the CA bundle the client certificates are verified with.
+// The bundle is read again once its file was modified, e.g. when the mounted
+// Secret was rotated. The previous bundle is kept if the new one is invalid.
+type clientCAPool struct {
+	path   string
+	logger logr.Logger
+
+	mu      sync.Mutex
+	modTime time.Time
+	pool    *x509.CertPool
+}
+
+func newClientCAPool(path string, logger logr.Logger) (*clientCAPool, error) {
+	c := &clientCAPool{path: path, logger: logger}
+	if _, err := c.Pool(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Pool returns the current CA pool, reading the bundle again if it changed.
+func (c *clientCAPool) Pool() (*x509.CertPool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return c.keep(fmt.Errorf("failed to read client CA cert: %w", err))
+	}
+
+	if c.pool != nil && info.ModTime().Equal(c.modTime) {
+		return c.pool, nil
+	}
+
+	clientCABytes, err := os.ReadFile(c.path)
+	if err != nil {
+		return c.keep(fmt.Errorf("failed to read client CA cert: %w", err))
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(clientCABytes) {
+		return c.keep(errors.New("failed to append client CA cert to CA pool"))
+	}
+
+	if c.pool != nil {
+		c.logger.Info("reloaded client CA cert", "path", c.path)
+	}
+
+	c.pool = pool
+	c.modTime = info.ModTime()
+	return pool, nil
+}
+
+func (c *clientCAPool) keep(err error) (*x509.CertPool, error) {
+	if c.pool == nil {
+		return nil, err
+	}
+
+	c.logger.Error(err, "keeping the previous client CA cert", "path", c.path)
+	return c.pool, nil
+}
+
+// GetConfigForClient verifies the client certificates of every handshake with
+// the current CA pool.
+func (c *clientCAPool) GetConfigForClient(cfg *tls.Config) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		pool, err := c.Pool()
+		if err != nil {
+			return nil, err
+		}
+
+		clientCfg := cfg.Clone()
+		clientCfg.GetConfigForClient = nil
+		clientCfg.ClientCAs = pool
+		return clientCfg, nil
+	}
+}
+
+// withClientCertificate rejects the requests without a verified client
+// certificate. The frontend is served without one, as browsers load it
+// before they could present one, and so are the uploads of the scan Jobs,
+// which are authenticated by their tokens instead.
+func withClientCertificate(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		public := r.Method == http.MethodGet && publicPaths[r.URL.Path]
+		upload := r.Method == http.MethodPut && r.URL.Path == "/scan-results"
+		if public || upload || (r.TLS != nil && len(r.TLS.VerifiedChains) > 0) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		writeProblem(w, r, unauthorized, "a client certificate is required")
+	})
+}
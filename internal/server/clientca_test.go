@@ -0,0 +1,100 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+func poolOf(certificate tls.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate.Leaf)
+	return pool
+}
+
+func TestClientCAPoolReloads(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "client.crt")
+	first := writeCertificate(t, dir, "client")
+
+	clientCAs, err := newClientCAPool(path, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool, err := clientCAs.Pool()
+	if err != nil || !pool.Equal(poolOf(first)) {
+		t.Fatalf("expected the pool of the first CA, got %v", err)
+	}
+
+	second := writeCertificate(t, dir, "client")
+	modTime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if pool, err := clientCAs.Pool(); err != nil || !pool.Equal(poolOf(second)) {
+		t.Errorf("expected the pool of the rotated CA, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("invalid"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime = modTime.Add(time.Minute)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if pool, err := clientCAs.Pool(); err != nil || !pool.Equal(poolOf(second)) {
+		t.Errorf("expected the pool of the rotated CA to be kept, got %v", err)
+	}
+}
+
+func TestNewClientCAPoolRejectsInvalidBundle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.crt")
+	if err := os.WriteFile(path, []byte("invalid"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newClientCAPool(path, logr.Discard()); err == nil {
+		t.Error("expected an invalid client CA bundle to be rejected")
+	}
+}
+
+func TestWithClientCertificate(t *testing.T) {
+	handler := withClientCertificate(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{}}}
+	cases := []struct {
+		method string
+		path   string
+		tls    *tls.ConnectionState
+		status int
+	}{
+		{http.MethodGet, "/", &tls.ConnectionState{}, http.StatusNoContent},
+		{http.MethodGet, "/bundle.js", &tls.ConnectionState{}, http.StatusNoContent},
+		{http.MethodPut, "/scan-results", &tls.ConnectionState{}, http.StatusNoContent},
+		{http.MethodGet, "/scan-results", &tls.ConnectionState{}, http.StatusUnauthorized},
+		{http.MethodDelete, "/scan-results/alpine", &tls.ConnectionState{}, http.StatusUnauthorized},
+		{http.MethodGet, "/scan-results", verified, http.StatusNoContent},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.path, nil)
+		r.TLS = c.tls
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("%s %s: expected status %d, got %d", c.method, c.path, c.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/events"
+	"github.com/kerezsiz42/scanner-operator2/internal/oapi"
+	"github.com/kerezsiz42/scanner-operator2/internal/service"
+)
+
+// eventFilter selects the events sent to a subscriber. Empty fields match
+// every event.
+type eventFilter struct {
+	namespaces   []string
+	repositories []string
+	minSeverity  int
+	types        []events.Type
+	// visibility is set by the server and can not be replaced by the
+	// subscriber.
+	visibility *visibility
+}
+
+// newEventFilter validates the filter sent by a subscriber, either as query
+// parameters or as a message.
+func newEventFilter(filter oapi.EventFilter) (*eventFilter, bool) {
+	f := &eventFilter{}
+	if filter.Namespaces != nil {
+		f.namespaces = *filter.Namespaces
+	}
+
+	if filter.Repositories != nil {
+		f.repositories = *filter.Repositories
+	}
+
+	if filter.MinSeverity != nil {
+		f.minSeverity = service.SeverityRank(string(*filter.MinSeverity))
+		if f.minSeverity == 0 {
+			return nil, false
+		}
+	}
+
+	if filter.Types != nil {
+		for _, eventType := range *filter.Types {
+			switch events.Type(eventType) {
+			case events.ScanResultCreated, events.ScanResultUpdated, events.ScanResultDeleted,
+				events.ScanStarted, events.ScanFailed:
+				f.types = append(f.types, events.Type(eventType))
+			default:
+				return nil, false
+			}
+		}
+	}
+
+	return f, true
+}
+
+func (f *eventFilter) matches(event events.Event) bool {
+	if f.visibility != nil && !f.visibility.sees(event.Namespaces) {
+		return false
+	}
+
+	if len(f.types) > 0 && !slices.Contains(f.types, event.Type) {
+		return false
+	}
+
+	if len(f.namespaces) > 0 && !slices.ContainsFunc(event.Namespaces, func(namespace string) bool {
+		return slices.Contains(f.namespaces, namespace)
+	}) {
+		return false
+	}
+
+	// The images are pulled by digest, so the repository is the part of the
+	// image ID before the @, like in ListScanResults.
+	if len(f.repositories) > 0 && !slices.ContainsFunc(f.repositories, func(repository string) bool {
+		return strings.HasPrefix(event.ImageID, repository+"@")
+	}) {
+		return false
+	}
+
+	if f.minSeverity > 0 && event.Summary != nil {
+		counts := map[string]int{
+			"critical": event.Summary.CriticalCount,
+			"high":     event.Summary.HighCount,
+			"medium":   event.Summary.MediumCount,
+			"low":      event.Summary.LowCount,
+			"info":     event.Summary.InfoCount,
+		}
+
+		for severity, count := range counts {
+			if count > 0 && service.SeverityRank(severity) >= f.minSeverity {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"github.com/kerezsiz42/scanner-operator2/internal/events"
+	"github.com/kerezsiz42/scanner-operator2/internal/oapi"
+)
+
+func TestEventFilter(t *testing.T) {
+	created := events.Event{
+		Type:       events.ScanResultCreated,
+		ImageID:    "docker.io/library/alpine@sha256:1",
+		Namespaces: []string{"default", "team-a"},
+		Summary:    &database.VulnerabilitySummary{MediumCount: 2, LowCount: 1},
+	}
+	started := events.Event{
+		Type:       events.ScanStarted,
+		ImageID:    "docker.io/library/debian@sha256:2",
+		Namespaces: []string{"team-b"},
+	}
+
+	strings := func(values ...string) *[]string { return &values }
+	severity := func(severity oapi.Severity) *oapi.Severity { return &severity }
+	tests := []struct {
+		name    string
+		filter  oapi.EventFilter
+		created bool
+		started bool
+	}{
+		{"empty", oapi.EventFilter{}, true, true},
+		{"namespace", oapi.EventFilter{Namespaces: strings("team-a", "team-c")}, true, false},
+		{"repository", oapi.EventFilter{Repositories: strings("docker.io/library/debian")}, false, true},
+		{"repository prefix", oapi.EventFilter{Repositories: strings("docker.io/library/alp")}, false, false},
+		{"medium", oapi.EventFilter{MinSeverity: severity(oapi.Medium)}, true, true},
+		{"high", oapi.EventFilter{MinSeverity: severity(oapi.High)}, false, true},
+		{"types", oapi.EventFilter{Types: &[]oapi.EventType{oapi.ScanStarted, oapi.ScanFailed}}, false, true},
+		{
+			"combined",
+			oapi.EventFilter{Namespaces: strings("default"), MinSeverity: severity(oapi.Low), Types: &[]oapi.EventType{oapi.ScanResultCreated}},
+			true,
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		filter, ok := newEventFilter(test.filter)
+		if !ok {
+			t.Fatalf("%s: unexpected invalid filter", test.name)
+		}
+
+		if filter.matches(created) != test.created {
+			t.Errorf("%s: expected the created event to match: %v", test.name, test.created)
+		}
+
+		if filter.matches(started) != test.started {
+			t.Errorf("%s: expected the started event to match: %v", test.name, test.started)
+		}
+	}
+
+	invalid := []oapi.EventFilter{
+		{MinSeverity: severity("severe")},
+		{Types: &[]oapi.EventType{"scanResultRenamed"}},
+	}
+	for _, filter := range invalid {
+		if _, ok := newEventFilter(filter); ok {
+			t.Errorf("expected the filter to be invalid: %+v", filter)
+		}
+	}
+}
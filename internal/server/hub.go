@@ -0,0 +1,231 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/events"
+	"github.com/kerezsiz42/scanner-operator2/internal/utils"
+)
+
+var errHubClosed = errors.New("hub is closed")
+
+// slowSubscriberPolicy decides what happens to a subscriber whose buffer is
+// full when an event is published.
+type slowSubscriberPolicy int
+
+const (
+	// disconnectSlowSubscribers drops the subscriber, which reloads the
+	// ScanResults when it reconnects, so it can not miss an event unnoticed.
+	disconnectSlowSubscribers slowSubscriberPolicy = iota
+	// dropEvents keeps the subscriber but skips the events it has no room for.
+	dropEvents
+)
+
+type hubOptions struct {
+	bufferSize  int
+	policy      slowSubscriberPolicy
+	historySize int
+}
+
+var defaultHubOptions = hubOptions{
+	bufferSize:  64,
+	policy:      disconnectSlowSubscribers,
+	historySize: 1000,
+}
+
+// hub fans out the published events to the subscribers. Publishing never
+// blocks: every subscriber has a bounded buffer drained by its own writer, and
+// the slowSubscriberPolicy is applied when the buffer is full. The last
+// historySize events are kept, so that subscribers can resume after a
+// reconnect. The IDs of the events published on this replica are prefixed
+// with the epoch of the hub, as the sequence starts over with every hub, e.g.
+// after a restart. The events of the other replicas keep their IDs, so that
+// a subscriber can resume on any replica which has them.
+type hub struct {
+	options     hubOptions
+	epoch       string
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+	sequence    uint64
+	history     []events.Event
+	closed      bool
+}
+
+type subscriber struct {
+	events chan events.Event
+	// done is closed when the subscriber is removed from the hub.
+	done   chan struct{}
+	filter atomic.Pointer[eventFilter]
+}
+
+// setFilter replaces the filter of the events queued for the subscriber.
+func (s *subscriber) setFilter(filter *eventFilter) {
+	s.filter.Store(filter)
+}
+
+func (s *subscriber) matches(event events.Event) bool {
+	filter := s.filter.Load()
+	return filter == nil || filter.matches(event)
+}
+
+func newHub(options hubOptions) *hub {
+	return &hub{
+		options:     options,
+		epoch:       utils.GenerateId(),
+		subscribers: map[*subscriber]struct{}{},
+		history:     make([]events.Event, options.historySize),
+	}
+}
+
+func (h *hub) subscribe(filter *eventFilter) (*subscriber, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.add(filter)
+}
+
+// resume subscribes to the events published after the event identified by
+// lastEventID and returns the ones published already. The returned bool is
+// false if some of them are not in the history anymore, or lastEventID is
+// unknown to this hub, in which case the subscriber has to reload the
+// ScanResults.
+func (h *hub) resume(lastEventID string, filter *eventFilter) (*subscriber, []events.Event, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, err := h.add(filter)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	lastSequence, ok := h.sequenceOf(lastEventID)
+	if !ok {
+		return s, nil, false, nil
+	}
+
+	missed := []events.Event{}
+	for sequence := lastSequence + 1; sequence <= h.sequence; sequence++ {
+		if event := h.history[h.historyIndex(sequence)]; s.matches(event) {
+			missed = append(missed, event)
+		}
+	}
+
+	return s, missed, true, nil
+}
+
+func (h *hub) unsubscribe(s *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(s)
+}
+
+// publish numbers the event and queues it for every subscriber. The numbered
+// event is returned.
+func (h *hub) publish(event events.Event) events.Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sequence++
+	event.Sequence = h.sequence
+	if event.ID == "" {
+		event.ID = fmt.Sprintf("%s-%d", h.epoch, event.Sequence)
+	}
+	if h.options.historySize > 0 {
+		h.history[h.historyIndex(event.Sequence)] = event
+	}
+
+	for s := range h.subscribers {
+		if !s.matches(event) {
+			continue
+		}
+
+		select {
+		case s.events <- event:
+		default:
+			if h.options.policy == disconnectSlowSubscribers {
+				h.remove(s)
+			}
+		}
+	}
+
+	return event
+}
+
+// close removes every subscriber and rejects the new ones.
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for s := range h.subscribers {
+		h.remove(s)
+	}
+}
+
+func (h *hub) len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subscribers)
+}
+
+// add must be called with h.mu held.
+func (h *hub) add(filter *eventFilter) (*subscriber, error) {
+	if h.closed {
+		return nil, errHubClosed
+	}
+
+	s := &subscriber{
+		events: make(chan events.Event, h.options.bufferSize),
+		done:   make(chan struct{}),
+	}
+	s.setFilter(filter)
+	h.subscribers[s] = struct{}{}
+
+	return s, nil
+}
+
+// sequenceOf returns the sequence of the event in the history, which is
+// parsed from the IDs of the events of this hub, and looked up for the IDs of
+// the events of the other replicas. It must be called with h.mu held.
+func (h *hub) sequenceOf(id string) (uint64, bool) {
+	kept := min(h.sequence, uint64(h.options.historySize))
+	epoch, sequence, _ := strings.Cut(id, "-")
+	if epoch == h.epoch {
+		lastSequence, err := strconv.ParseUint(sequence, 10, 64)
+		if err != nil || lastSequence > h.sequence || lastSequence < h.sequence-kept {
+			return 0, false
+		}
+
+		return lastSequence, true
+	}
+
+	for lastSequence := h.sequence; lastSequence > h.sequence-kept; lastSequence-- {
+		if h.history[h.historyIndex(lastSequence)].ID == id {
+			return lastSequence, true
+		}
+	}
+
+	return 0, false
+}
+
+func (h *hub) historyIndex(sequence uint64) int {
+	return int((sequence - 1) % uint64(h.options.historySize))
+}
+
+// remove must be called with h.mu held. It closes done only once, as the
+// subscriber is only closed while it is still in the map.
+func (h *hub) remove(s *subscriber) {
+	if _, ok := h.subscribers[s]; !ok {
+		return
+	}
+
+	delete(h.subscribers, s)
+	close(s.done)
+}
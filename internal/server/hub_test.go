@@ -0,0 +1,223 @@
+package server
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/events"
+)
+
+func TestHubFanOut(t *testing.T) {
+	const (
+		subscribers = 50
+		publishers  = 8
+		perProducer = 100
+		total       = publishers * perProducer
+	)
+
+	h := newHub(hubOptions{bufferSize: total, policy: disconnectSlowSubscribers})
+	received := make([][]uint64, subscribers)
+	var wg sync.WaitGroup
+	for i := 0; i < subscribers; i++ {
+		sub, err := h.subscribe(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for len(received[i]) < total {
+				event := <-sub.events
+				received[i] = append(received[i], event.Sequence)
+			}
+		}(i)
+	}
+
+	var publishing sync.WaitGroup
+	for i := 0; i < publishers; i++ {
+		publishing.Add(1)
+		go func() {
+			defer publishing.Done()
+			for j := 0; j < perProducer; j++ {
+				h.publish(events.Event{Type: events.ScanResultCreated, ImageID: "alpine"})
+			}
+		}()
+	}
+
+	publishing.Wait()
+	wg.Wait()
+
+	for i, sequences := range received {
+		for j, sequence := range sequences {
+			if sequence != uint64(j+1) {
+				t.Fatalf("subscriber %d received sequence %d at position %d", i, sequence, j)
+			}
+		}
+	}
+}
+
+func TestHubSlowSubscriber(t *testing.T) {
+	for _, policy := range []slowSubscriberPolicy{disconnectSlowSubscribers, dropEvents} {
+		h := newHub(hubOptions{bufferSize: 4, policy: policy})
+		slow, err := h.subscribe(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		published := make(chan struct{})
+		go func() {
+			defer close(published)
+			for i := 0; i < 100; i++ {
+				h.publish(events.Event{Type: events.ScanResultUpdated, ImageID: "alpine"})
+			}
+		}()
+
+		select {
+		case <-published:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("policy %d: publishing is blocked by the slow subscriber", policy)
+		}
+
+		select {
+		case <-slow.done:
+			if policy == dropEvents {
+				t.Errorf("the slow subscriber is disconnected although events should be dropped")
+			}
+		default:
+			if policy == disconnectSlowSubscribers {
+				t.Errorf("the slow subscriber is not disconnected")
+			}
+		}
+
+		if policy == dropEvents && len(slow.events) != 4 {
+			t.Errorf("expected the buffer of the slow subscriber to be full, got %d events", len(slow.events))
+		}
+	}
+}
+
+func TestHubClose(t *testing.T) {
+	h := newHub(defaultHubOptions)
+	subs := []*subscriber{}
+	for i := 0; i < 10; i++ {
+		sub, err := h.subscribe(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		subs = append(subs, sub)
+	}
+
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub *subscriber) {
+			defer wg.Done()
+			h.unsubscribe(sub)
+		}(sub)
+	}
+
+	h.close()
+	wg.Wait()
+
+	for _, sub := range subs {
+		<-sub.done
+	}
+
+	if h.len() != 0 {
+		t.Errorf("expected no subscribers, got %d", h.len())
+	}
+
+	if _, err := h.subscribe(nil); err != errHubClosed {
+		t.Errorf("expected errHubClosed, got %v", err)
+	}
+}
+
+func TestHubResume(t *testing.T) {
+	tests := []struct {
+		lastEventID string
+		missed      []uint64
+		resumed     bool
+	}{
+		{lastEventID: "6", missed: []uint64{}, resumed: true},
+		{lastEventID: "3", missed: []uint64{4, 5, 6}, resumed: true},
+		{lastEventID: "2", missed: []uint64{3, 4, 5, 6}, resumed: true},
+		{lastEventID: "1", resumed: false},
+		{lastEventID: "7", resumed: false},
+		// The sequence alone, or with the epoch of another hub, was not
+		// published by this hub.
+		{lastEventID: "other-3", resumed: false},
+		{lastEventID: "abc", resumed: false},
+	}
+
+	for _, test := range tests {
+		h := newHub(hubOptions{bufferSize: 8, policy: disconnectSlowSubscribers, historySize: 4})
+		for i := 0; i < 6; i++ {
+			h.publish(events.Event{Type: events.ScanResultCreated, ImageID: "alpine"})
+		}
+
+		lastEventID := test.lastEventID
+		if _, err := strconv.Atoi(lastEventID); err == nil {
+			lastEventID = h.epoch + "-" + lastEventID
+		}
+
+		sub, missed, resumed, err := h.resume(lastEventID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resumed != test.resumed {
+			t.Errorf("resuming after %s: expected resumed to be %v", test.lastEventID, test.resumed)
+		}
+
+		sequences := []uint64{}
+		for _, event := range missed {
+			sequences = append(sequences, event.Sequence)
+		}
+
+		if test.resumed && !slices.Equal(sequences, test.missed) {
+			t.Errorf("resuming after %s: expected %v, got %v", test.lastEventID, test.missed, sequences)
+		}
+
+		h.publish(events.Event{Type: events.ScanResultUpdated, ImageID: "alpine"})
+		if event := <-sub.events; event.Sequence != 7 {
+			t.Errorf("resuming after %s: expected the next event to be 7, got %d", test.lastEventID, event.Sequence)
+		}
+	}
+}
+
+func TestHubResumeRelayedEvents(t *testing.T) {
+	h := newHub(hubOptions{bufferSize: 8, policy: disconnectSlowSubscribers, historySize: 4})
+	h.publish(events.Event{Type: events.ScanStarted, ImageID: "alpine", ID: "other-1"})
+	local := h.publish(events.Event{Type: events.ScanStarted, ImageID: "debian"})
+	h.publish(events.Event{Type: events.ScanResultCreated, ImageID: "alpine", ID: "other-2"})
+
+	if local.ID != h.epoch+"-2" {
+		t.Errorf("expected the local event to be identified by the epoch of the hub, got %s", local.ID)
+	}
+
+	_, missed, resumed, err := h.resume("other-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+	for _, event := range missed {
+		ids = append(ids, event.ID)
+	}
+
+	if !resumed || !slices.Equal(ids, []string{local.ID, "other-2"}) {
+		t.Errorf("expected to resume after the relayed event, got %v (%v)", ids, resumed)
+	}
+
+	for i := 0; i < 4; i++ {
+		h.publish(events.Event{Type: events.ScanStarted, ImageID: "nginx"})
+	}
+
+	if _, _, resumed, _ := h.resume("other-2", nil); resumed {
+		t.Error("expected not to resume after a relayed event which is not in the history anymore")
+	}
+}
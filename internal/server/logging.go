@@ -0,0 +1,17 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-logr/logr"
+)
+
+// WithRequestLogger puts a logger with the method and the path of the request
+// into its context, so that the services log with them, e.g. the slow queries
+// of the database.
+func WithRequestLogger(logger logr.Logger, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestLogger := logger.WithValues("method", r.Method, "path", r.URL.Path)
+		handler.ServeHTTP(w, r.WithContext(logr.NewContext(r.Context(), requestLogger)))
+	})
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/oapi"
+)
+
+// problemTypeBase is the prefix of the types of the problems, which
+// identify the kinds of errors returned by the API.
+const problemTypeBase = "https://scanner.zoltankerezsi.xyz/problems/"
+
+// problemKind is a kind of error with its RFC 7807 type, title and status.
+type problemKind struct {
+	name   string
+	title  string
+	status int
+}
+
+var (
+	invalidParameter    = problemKind{"invalid-parameter", "Invalid parameter", http.StatusBadRequest}
+	invalidBody         = problemKind{"invalid-body", "Invalid request body", http.StatusBadRequest}
+	invalidCycloneDX    = problemKind{"invalid-cyclonedx-bom", "Invalid CycloneDX BOM", http.StatusBadRequest}
+	invalidCursor       = problemKind{"invalid-cursor", "Invalid cursor", http.StatusBadRequest}
+	unauthorized        = problemKind{"unauthorized", "Unauthorized", http.StatusUnauthorized}
+	forbidden           = problemKind{"forbidden", "Forbidden", http.StatusForbidden}
+	uploadTokenMissing  = problemKind{"upload-token-missing", "Upload token missing", http.StatusForbidden}
+	uploadTokenRejected = problemKind{"upload-token-rejected", "Upload token rejected", http.StatusForbidden}
+	imageIDMismatch     = problemKind{"image-id-mismatch", "Image ID mismatch", http.StatusForbidden}
+	notFound            = problemKind{"not-found", "Not found", http.StatusNotFound}
+	payloadTooLarge     = problemKind{"payload-too-large", "Payload too large", http.StatusRequestEntityTooLarge}
+	internalError       = problemKind{"internal-error", "Internal server error", http.StatusInternalServerError}
+	unavailable         = problemKind{"unavailable", "Service unavailable", http.StatusServiceUnavailable}
+)
+
+func (k problemKind) Type() string {
+	return problemTypeBase + k.name
+}
+
+// writeProblem responds with an application/problem+json body describing the
+// error. The detail is left out if empty, it must not leak internal errors.
+func writeProblem(w http.ResponseWriter, r *http.Request, kind problemKind, detail string) {
+	problem := oapi.Problem{
+		Type:     kind.Type(),
+		Title:    kind.title,
+		Status:   kind.status,
+		Instance: &r.URL.Path,
+	}
+
+	if detail != "" {
+		problem.Detail = &detail
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(kind.status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+// ParameterErrorHandler is the ErrorHandlerFunc of the API, responding with
+// an invalid-parameter problem when the parameters of a request can not be
+// bound, e.g. a limit which is not a number.
+func ParameterErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblem(w, r, invalidParameter, err.Error())
+}
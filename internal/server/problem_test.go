@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"gorm.io/gorm"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"github.com/kerezsiz42/scanner-operator2/internal/events"
+	"github.com/kerezsiz42/scanner-operator2/internal/oapi"
+	"github.com/kerezsiz42/scanner-operator2/internal/service"
+)
+
+// fakeMissingScanService has no ScanResults and rejects every report.
+type fakeMissingScanService struct {
+	service.ScanServiceInterface
+}
+
+func (fakeMissingScanService) GetScanResult(_ context.Context, imageId string) (*database.ScanResult, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (fakeMissingScanService) UpsertScanResult(
+	_ context.Context,
+	imageId string,
+	report string,
+	metadata database.ScanMetadata,
+	triggers []database.ScanTrigger,
+) (*database.ScanResult, bool, error) {
+	return nil, false, fmt.Errorf("%w: missing bomFormat", service.InvalidCycloneDXBOM)
+}
+
+func (fakeMissingScanService) ImageNamespaces(_ context.Context, imageIds []string) (map[string][]string, error) {
+	return map[string][]string{}, nil
+}
+
+// repeatReader reads the byte forever.
+type repeatReader byte
+
+func (b repeatReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+
+	return len(p), nil
+}
+
+func TestProblems(t *testing.T) {
+	cases := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		body     io.Reader
+		expected problemKind
+	}{
+		{"invalid parameter", http.MethodGet, "/scan-results?limit=many", "", nil, invalidParameter},
+		{"limit out of range", http.MethodGet, "/scan-results?limit=0", "", nil, invalidParameter},
+		{"not found", http.MethodGet, "/scan-results/alpine", "", nil, notFound},
+		{"malformed body", http.MethodPut, "/scan-results", "valid", strings.NewReader(`{"imageId":`), invalidBody},
+		{"missing report", http.MethodPut, "/scan-results", "valid", strings.NewReader(`{"imageId":"alpine"}`), invalidBody},
+		{"missing token", http.MethodPut, "/scan-results", "", strings.NewReader(`{"imageId":"alpine","report":{}}`), uploadTokenMissing},
+		{"image ID mismatch", http.MethodPut, "/scan-results", "valid", strings.NewReader(`{"imageId":"nginx","report":{}}`), imageIDMismatch},
+		{"invalid token", http.MethodPut, "/scan-results", "forged", strings.NewReader(`{"imageId":"alpine","report":{}}`), uploadTokenRejected},
+		{"invalid CycloneDX", http.MethodPut, "/scan-results", "valid", strings.NewReader(`{"imageId":"alpine","report":{}}`), invalidCycloneDX},
+		{"payload too large", http.MethodPut, "/scan-results", "valid", io.MultiReader(
+			strings.NewReader(`{"imageId":"`), io.LimitReader(repeatReader('a'), maxScanResultSize),
+		), payloadTooLarge},
+	}
+
+	for _, c := range cases {
+		s := NewServer(fakeMissingScanService{}, nil, &fakeUploadTokens{}, nil, events.NewMemoryBus().Member(), logr.Discard())
+		handler := oapi.HandlerWithOptions(s, oapi.StdHTTPServerOptions{ErrorHandlerFunc: ParameterErrorHandler})
+
+		r := httptest.NewRequest(c.method, c.path, c.body)
+		if c.token != "" {
+			r.Header.Set("X-Upload-Token", c.token)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.expected.status || w.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("%s: expected a problem with status %d, got %d %s", c.name, c.expected.status, w.Code, w.Header().Get("Content-Type"))
+			continue
+		}
+
+		problem := oapi.Problem{}
+		decoder := json.NewDecoder(w.Body)
+		if err := decoder.Decode(&problem); err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+
+		if problem.Type != c.expected.Type() || problem.Status != c.expected.status ||
+			problem.Instance == nil || *problem.Instance != r.URL.Path {
+			t.Errorf("%s: unexpected problem %+v", c.name, problem)
+		}
+
+		// The handlers used to write a ScanResult after a 404.
+		if decoder.More() {
+			t.Errorf("%s: unexpected data after the problem", c.name)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+)
+
+// HTTPServerOptions configures how the Scanner API is served.
+type HTTPServerOptions struct {
+	BindAddress string
+	// LeaderElection serves the API on the leader only. Otherwise every
+	// replica serves it from the shared database, and the events are
+	// exchanged through the event bus.
+	LeaderElection bool
+
+	// CertDir holds the certificate and the key the API is served with. The
+	// API is served over plain HTTP if empty. The certificate is reloaded when
+	// the files change, e.g. when cert-manager rotates the mounted Secret.
+	CertDir  string
+	CertName string
+	KeyName  string
+	// ClientCAName is the CA bundle in CertDir the certificates of the clients
+	// are verified with. If set, the clients are required to present a
+	// certificate, except for loading the frontend and uploading the reports
+	// of the scan Jobs. The bundle is reloaded when it changes, like the
+	// certificate.
+	ClientCAName string
+	TLSOpts      []func(*tls.Config)
+
+	// ReadTimeout and WriteTimeout do not apply to the event streams, which
+	// set their own deadlines.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// ShutdownTimeout bounds how long the requests in progress are waited for
+	// after the subscribers were disconnected.
+	ShutdownTimeout time.Duration
+}
+
+// HTTPServer serves the Scanner API as a Runnable of the manager.
+type HTTPServer struct {
+	server  *Server
+	handler http.Handler
+	options HTTPServerOptions
+	logger  logr.Logger
+	// listening is set while the listener accepts connections.
+	listening atomic.Bool
+}
+
+func NewHTTPServer(server *Server, handler http.Handler, options HTTPServerOptions, logger logr.Logger) *HTTPServer {
+	if options.CertName == "" {
+		options.CertName = "tls.crt"
+	}
+
+	if options.KeyName == "" {
+		options.KeyName = "tls.key"
+	}
+
+	return &HTTPServer{
+		server:  server,
+		handler: handler,
+		options: options,
+		logger:  logger,
+	}
+}
+
+func (h *HTTPServer) NeedLeaderElection() bool {
+	return h.options.LeaderElection
+}
+
+// ReadyzCheck fails unless the API is listening. The replicas which are not
+// the leader pass when the API is served by the leader only, so that they do
+// not block rollouts.
+func (h *HTTPServer) ReadyzCheck(_ *http.Request) error {
+	if h.listening.Load() {
+		return nil
+	}
+
+	if h.options.LeaderElection {
+		return nil
+	}
+
+	return errors.New("the Scanner API is not listening")
+}
+
+// Start serves the API until the context is cancelled. Then the subscribers
+// are disconnected before the requests in progress are drained.
+func (h *HTTPServer) Start(ctx context.Context) error {
+	listener, err := h.listen(ctx)
+	if err != nil {
+		return err
+	}
+
+	handler := h.handler
+	if h.options.CertDir != "" && h.options.ClientCAName != "" {
+		handler = withClientCertificate(handler)
+	}
+
+	srv := &http.Server{
+		Handler:      handler,
+		ReadTimeout:  h.options.ReadTimeout,
+		WriteTimeout: h.options.WriteTimeout,
+		IdleTimeout:  h.options.IdleTimeout,
+	}
+
+	// Run only returns once its context is cancelled, which is done before
+	// waiting for it when the API can not be served.
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	runDone := make(chan struct{})
+	go func() {
+		defer close(runDone)
+		h.server.Run(runCtx)
+	}()
+
+	serveErr := make(chan error, 1)
+	h.listening.Store(true)
+	go func() {
+		defer h.listening.Store(false)
+		h.logger.Info("serving Scanner API", "address", listener.Addr().String(), "tls", h.options.CertDir != "")
+		serveErr <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		cancelRun()
+		<-runDone
+		return fmt.Errorf("failed to serve Scanner API: %w", err)
+	case <-ctx.Done():
+	}
+
+	// The websocket connections are hijacked, so they are closed by Run
+	// instead of Shutdown.
+	<-runDone
+	h.logger.Info("shutting down Scanner API", "timeout", h.options.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), h.options.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down Scanner API: %w", err)
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve Scanner API: %w", err)
+	}
+
+	return nil
+}
+
+func (h *HTTPServer) listen(ctx context.Context) (net.Listener, error) {
+	if h.options.CertDir == "" {
+		return net.Listen("tcp", h.options.BindAddress)
+	}
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	for _, op := range h.options.TLSOpts {
+		op(cfg)
+	}
+
+	certWatcher, err := certwatcher.New(
+		filepath.Join(h.options.CertDir, h.options.CertName),
+		filepath.Join(h.options.CertDir, h.options.KeyName),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Scanner API certificate: %w", err)
+	}
+
+	cfg.GetCertificate = certWatcher.GetCertificate
+	go func() {
+		if err := certWatcher.Start(ctx); err != nil {
+			h.logger.Error(err, "certificate watcher error")
+		}
+	}()
+
+	if h.options.ClientCAName != "" {
+		clientCAs, err := newClientCAPool(filepath.Join(h.options.CertDir, h.options.ClientCAName), h.logger)
+		if err != nil {
+			return nil, err
+		}
+
+		// The certificate is required by withClientCertificate on the routes
+		// which are not public.
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		cfg.GetConfigForClient = clientCAs.GetConfigForClient(cfg.Clone())
+	}
+
+	return tls.Listen("tcp", h.options.BindAddress, cfg)
+}
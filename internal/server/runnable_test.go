@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/gorilla/websocket"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/events"
+	"github.com/kerezsiz42/scanner-operator2/internal/oapi"
+)
+
+// writeCertificate writes a self-signed certificate for 127.0.0.1 and its
+// key to the directory, and returns the certificate.
+func writeCertificate(t *testing.T, dir string, name string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certificate
+}
+
+func TestHTTPServer(t *testing.T) {
+	dir := t.TempDir()
+	serverCertificate := writeCertificate(t, dir, "tls")
+	clientCertificate := writeCertificate(t, dir, "client")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := listener.Addr().String()
+	listener.Close()
+
+	s := NewServer(nil, nil, nil, nil, events.NewMemoryBus().Member(), logr.Discard())
+	h := NewHTTPServer(s, oapi.Handler(s), HTTPServerOptions{
+		BindAddress:     address,
+		CertDir:         dir,
+		ClientCAName:    "client.crt",
+		ShutdownTimeout: 5 * time.Second,
+	}, logr.Discard())
+
+	if err := h.ReadyzCheck(nil); err == nil {
+		t.Error("expected not to be ready before listening")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	started := make(chan error, 1)
+	go func() {
+		started <- h.Start(ctx)
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCertificate.Leaf)
+	dialer := &websocket.Dialer{
+		TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCertificate}},
+	}
+
+	var c *websocket.Conn
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if c, _, err = dialer.Dial("wss://"+address+"/subscribe", nil); err == nil {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	defer c.Close()
+
+	if err := h.ReadyzCheck(nil); err != nil {
+		t.Errorf("expected to be ready while listening, got %v", err)
+	}
+
+	withoutCertificate := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	for path, status := range map[string]int{"/": http.StatusOK, "/scan-results": http.StatusUnauthorized} {
+		res, err := withoutCertificate.Get("https://" + address + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res.Body.Close()
+		if res.StatusCode != status {
+			t.Errorf("expected status %d of %s without a client certificate, got %d", status, path, res.StatusCode)
+		}
+	}
+
+	waitForSubscribers(t, s, 1)
+	cancel()
+
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := c.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("expected the subscriber to be disconnected, got %v", err)
+	}
+
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the server did not shut down")
+	}
+
+	if err := h.ReadyzCheck(nil); err == nil {
+		t.Error("expected not to be ready after shutting down")
+	}
+}
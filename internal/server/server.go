@@ -1,51 +1,104 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
-	"sync"
+	"slices"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/gorilla/websocket"
 	"github.com/kerezsiz42/scanner-operator2/frontend"
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"github.com/kerezsiz42/scanner-operator2/internal/events"
+	"github.com/kerezsiz42/scanner-operator2/internal/metrics"
 	"github.com/kerezsiz42/scanner-operator2/internal/oapi"
 	"github.com/kerezsiz42/scanner-operator2/internal/service"
 	"gorm.io/gorm"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+// The timeouts and the message size limit of the websocket connections of the
+// subscribers.
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = pongWait * 9 / 10
+	maxMessageSize = 64 * 1024
 )
 
 type Server struct {
-	scanService service.ScanServiceInterface
-	upgrader    *websocket.Upgrader
-	logger      logr.Logger
-	broadcastCh chan string
-	connections map[*websocket.Conn]chan string
-	mu          sync.Mutex
+	scanService     service.ScanServiceInterface
+	workloadService service.WorkloadServiceInterface
+	uploadTokens    service.UploadTokenServiceInterface
+	authz           authorizer.Authorizer
+	visibilities    *visibilityCache
+	upgrader        *websocket.Upgrader
+	logger          logr.Logger
+	hub             *hub
+	bus             events.Bus
 }
 
 func NewServer(
 	scanService service.ScanServiceInterface,
+	workloadService service.WorkloadServiceInterface,
+	uploadTokens service.UploadTokenServiceInterface,
+	authz authorizer.Authorizer,
+	bus events.Bus,
 	logger logr.Logger,
 ) *Server {
-	broadcastCh := make(chan string)
-	connections := make(map[*websocket.Conn]chan string)
-	go func() {
-		for {
-			message := <-broadcastCh
+	return &Server{
+		upgrader:        &websocket.Upgrader{},
+		scanService:     scanService,
+		workloadService: workloadService,
+		uploadTokens:    uploadTokens,
+		authz:           authz,
+		visibilities:    newVisibilityCache(),
+		logger:          logger,
+		hub:             newHub(defaultHubOptions),
+		bus:             bus,
+	}
+}
 
-			for _, ch := range connections {
-				ch <- message
-			}
+// Publish sends the event to every subscriber of this replica without waiting
+// for them, and to the other replicas through the Bus. The events are
+// numbered in the order they are published.
+func (s *Server) Publish(event events.Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	// The event is sent to the other replicas with the ID given by the hub.
+	event = s.hub.publish(event)
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+	if err := s.bus.Publish(ctx, event); err != nil {
+		s.logger.Error(err, "Publish", "type", event.Type, "imageId", event.ImageID)
+	}
+}
+
+// Run sends the events of the other replicas to the subscribers, and
+// disconnects every subscriber when the context is cancelled. The websocket
+// connections are hijacked, so http.Server.Shutdown does not close them.
+func (s *Server) Run(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := s.bus.Run(ctx, func(event events.Event) {
+			s.hub.publish(event)
+		})
+		if err != nil {
+			s.logger.Error(err, "Run")
 		}
 	}()
-	return &Server{
-		upgrader:    &websocket.Upgrader{},
-		scanService: scanService,
-		logger:      logger,
-		broadcastCh: broadcastCh,
-		connections: connections,
-		mu:          sync.Mutex{},
-	}
+
+	<-ctx.Done()
+	s.hub.close()
+	<-done
 }
 
 func (s *Server) Get(w http.ResponseWriter, r *http.Request) {
@@ -69,68 +122,273 @@ func (s *Server) GetOutputCss(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(frontend.OutputCss)
 }
 
-func (s *Server) GetSubscribe(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetSubscribe(w http.ResponseWriter, r *http.Request, params oapi.GetSubscribeParams) {
 	defer observeDuration("GET", "/subscribe")()
-	c, err := s.upgrader.Upgrade(w, r, nil)
+	format := oapi.Envelope
+	if params.Format != nil {
+		format = *params.Format
+	}
+
+	if format != oapi.Envelope && format != oapi.Legacy {
+		writeProblem(w, r, invalidParameter, fmt.Sprintf("format %q is not supported", format))
+		return
+	}
+
+	filter, ok := newEventFilter(oapi.EventFilter{
+		Namespaces:   params.Namespace,
+		Repositories: params.Repository,
+		MinSeverity:  params.MinSeverity,
+		Types:        params.Type,
+	})
+	if !ok {
+		writeProblem(w, r, invalidParameter, eventFilterDetail)
+		return
+	}
+
+	v, err := s.visibility(r.Context())
 	if err != nil {
 		s.logger.Error(err, "GetSubscribe")
+		writeProblem(w, r, internalError, "")
 		return
 	}
 
-	defer c.Close()
+	filter.visibility = v
+	sub, err := s.hub.subscribe(filter)
+	if err != nil {
+		writeProblem(w, r, unavailable, "the server is shutting down")
+		return
+	}
 
-	ch := make(chan string)
-	defer close(ch)
+	defer s.hub.unsubscribe(sub)
 
-	s.mu.Lock()
-	s.connections[c] = ch
-	s.mu.Unlock()
+	c, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.logger.Error(err, "GetSubscribe")
+		return
+	}
 
+	defer c.Close()
+
+	// The subscribers can replace their filter by sending an EventFilter. The
+	// connection is read anyway to process the pongs and the close message.
+	readerDone := make(chan struct{})
 	go func() {
+		defer close(readerDone)
+		c.SetReadLimit(maxMessageSize)
+		_ = c.SetReadDeadline(time.Now().Add(pongWait))
+		c.SetPongHandler(func(string) error {
+			return c.SetReadDeadline(time.Now().Add(pongWait))
+		})
+
 		for {
-			imageId, ok := <-ch
-			if !ok {
+			_, data, err := c.ReadMessage()
+			if err != nil {
 				return
 			}
 
-			data := []byte("\"" + imageId + "\"")
-			if err := c.WriteMessage(websocket.TextMessage, data); err != nil {
-				s.logger.Error(err, "Websocket")
+			message := oapi.EventFilter{}
+			if err := json.Unmarshal(data, &message); err != nil {
+				s.logger.Error(err, "GetSubscribe")
+				return
+			}
+
+			filter, ok := newEventFilter(message)
+			if !ok {
+				s.logger.Info("GetSubscribe", "invalid filter", string(data))
+				return
 			}
+
+			filter.visibility = v
+			sub.setFilter(filter)
 		}
 	}()
 
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
 	for {
-		msgType, _, err := c.ReadMessage()
+		select {
+		case event := <-sub.events:
+			data, err := encodeEvent(event, format)
+			if err != nil {
+				s.logger.Error(err, "GetSubscribe")
+				continue
+			}
+
+			if data == nil {
+				continue
+			}
+
+			_ = c.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		case <-sub.done:
+			// The hub is closed or the subscriber fell behind.
+			message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+			_ = c.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+			return
+		case <-readerDone:
+			return
+		}
+	}
+}
+
+// eventFilterDetail explains the rejected EventFilters, which only fail on
+// the values outside of the enums of the schema.
+const eventFilterDetail = "minSeverity and type must be values of the Severity and EventType enums"
+
+// retryMillis is the reconnection delay sent to the Server-Sent Events clients.
+const retryMillis = 5000
+
+func (s *Server) GetEvents(w http.ResponseWriter, r *http.Request, params oapi.GetEventsParams) {
+	defer observeDuration("GET", "/events")()
+	filter, ok := newEventFilter(oapi.EventFilter{
+		Namespaces:   params.Namespace,
+		Repositories: params.Repository,
+		MinSeverity:  params.MinSeverity,
+		Types:        params.Type,
+	})
+	if !ok {
+		writeProblem(w, r, invalidParameter, eventFilterDetail)
+		return
+	}
+
+	v, err := s.visibility(r.Context())
+	if err != nil {
+		s.logger.Error(err, "GetEvents")
+		writeProblem(w, r, internalError, "")
+		return
+	}
+
+	filter.visibility = v
+	var sub *subscriber
+	var missed []events.Event
+	resumed := true
+	if params.LastEventID != nil {
+		// The ids of the events of another replica or of a previous run of
+		// the server reset the client instead of being rejected, which would
+		// stop the EventSource from reconnecting.
+		sub, missed, resumed, err = s.hub.resume(*params.LastEventID, filter)
+	} else {
+		sub, err = s.hub.subscribe(filter)
+	}
+
+	if err != nil {
+		writeProblem(w, r, unavailable, "the server is shutting down")
+		return
+	}
+
+	defer s.hub.unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	// The read deadline of the request would cancel the stream once the
+	// ReadTimeout of the server passed.
+	_ = rc.SetReadDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Disables the response buffering of nginx based proxies.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(message []byte) bool {
+		_ = rc.SetWriteDeadline(time.Now().Add(writeWait))
+		if _, err := w.Write(message); err != nil {
+			return false
+		}
+
+		return rc.Flush() == nil
+	}
+
+	message := fmt.Sprintf("retry: %d\n\n", retryMillis)
+	if !resumed {
+		message += "event: reset\ndata: {}\n\n"
+	}
+
+	if !write([]byte(message)) {
+		return
+	}
+
+	for _, event := range missed {
+		data, err := encodeServerSentEvent(event)
 		if err != nil {
-			break
+			s.logger.Error(err, "GetEvents")
+			continue
 		}
 
-		if msgType == websocket.CloseMessage {
-			break
+		if !write(data) {
+			return
 		}
 	}
 
-	s.mu.Lock()
-	delete(s.connections, c)
-	s.mu.Unlock()
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-sub.events:
+			data, err := encodeServerSentEvent(event)
+			if err != nil {
+				s.logger.Error(err, "GetEvents")
+				continue
+			}
+
+			if !write(data) {
+				return
+			}
+		case <-ticker.C:
+			// Comments are ignored by the clients, but keep the proxies
+			// from closing an idle connection.
+			if !write([]byte(": ping\n\n")) {
+				return
+			}
+		case <-sub.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
 }
 
-func (s *Server) GetScanResults(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetScanResults(w http.ResponseWriter, r *http.Request, params oapi.GetScanResultsParams) {
 	defer observeDuration("GET", "/scan-results")()
-	scanResults, err := s.scanService.ListScanResults()
+	options, err := toListScanResultsOptions(params)
 	if err != nil {
+		writeProblem(w, r, invalidParameter, err.Error())
+		return
+	}
+
+	v, err := s.visibility(r.Context())
+	if err != nil {
+		s.logger.Error(err, "GetScanResults")
+		writeProblem(w, r, internalError, "")
+		return
+	}
+
+	options.VisibleNamespaces = v.visibleNamespaces()
+
+	page, err := s.scanService.ListScanResults(r.Context(), options)
+	if errors.Is(err, service.InvalidCursor) {
+		s.logger.Error(err, "GetScanResults")
+		writeProblem(w, r, invalidCursor, "the cursor is not one returned in X-Next-Cursor")
+		return
+	} else if err != nil {
 		s.logger.Error(err, "GetScanResults")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeProblem(w, r, internalError, "")
 		return
 	}
 
 	res := []oapi.ScanResult{}
-	for _, scanResult := range scanResults {
-		res = append(res, oapi.ScanResult{
-			ImageId: scanResult.ImageID,
-			Report:  json.RawMessage(scanResult.Report),
-		})
+	for _, scanResult := range page.ScanResults {
+		res = append(res, toOapiScanResult(scanResult))
+	}
+
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -140,34 +398,127 @@ func (s *Server) GetScanResults(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Server) PutScanResults(w http.ResponseWriter, r *http.Request) {
+func (s *Server) PutScanResults(w http.ResponseWriter, r *http.Request, params oapi.PutScanResultsParams) {
 	defer observeDuration("PUT", "/scan-results")()
 	oapiScanResult := oapi.ScanResult{}
-	if err := json.NewDecoder(r.Body).Decode(&oapiScanResult); err != nil {
+	body := http.MaxBytesReader(w, r.Body, maxScanResultSize)
+	if err := json.NewDecoder(body).Decode(&oapiScanResult); err != nil {
+		s.logger.Error(err, "PutScanResults")
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeProblem(w, r, payloadTooLarge, fmt.Sprintf("the ScanResult must not be larger than %d bytes", maxBytesErr.Limit))
+			return
+		}
+
+		writeProblem(w, r, invalidBody, err.Error())
+		return
+	}
+
+	if oapiScanResult.Report == nil {
+		writeProblem(w, r, invalidBody, "report is required")
+		return
+	}
+
+	if params.XUploadToken == nil {
+		writeProblem(w, r, uploadTokenMissing, "the X-Upload-Token header is required")
+		return
+	}
+
+	// The token is only spent once the report was stored, so that the Job can
+	// retry after a rejected report or a failed upload.
+	if err := s.uploadTokens.Verify(r.Context(), *params.XUploadToken, oapiScanResult.ImageId); errors.Is(err, service.UploadTokenImageMismatch) {
+		s.logger.Info("PutScanResults", "imageId", oapiScanResult.ImageId, "rejected", err.Error())
+		writeProblem(w, r, imageIDMismatch, fmt.Sprintf("the upload token was not issued for image %s", oapiScanResult.ImageId))
+		return
+	} else if errors.Is(err, service.InvalidUploadToken) || errors.Is(err, service.ExpiredUploadToken) ||
+		errors.Is(err, service.ReusedUploadToken) {
+		s.logger.Info("PutScanResults", "imageId", oapiScanResult.ImageId, "rejected", err.Error())
+		writeProblem(w, r, uploadTokenRejected, err.Error())
+		return
+	} else if err != nil {
 		s.logger.Error(err, "PutScanResults")
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+		writeProblem(w, r, internalError, "")
 		return
 	}
 
-	scanResult, err := s.scanService.UpsertScanResult(oapiScanResult.ImageId, string(oapiScanResult.Report))
+	metadata := database.ScanMetadata{}
+	if oapiScanResult.ScannerName != nil {
+		metadata.ScannerName = *oapiScanResult.ScannerName
+	}
+
+	if oapiScanResult.ScannerVersion != nil {
+		metadata.ScannerVersion = *oapiScanResult.ScannerVersion
+	}
+
+	if oapiScanResult.DatabaseVersion != nil {
+		metadata.DatabaseVersion = *oapiScanResult.DatabaseVersion
+	}
+
+	if oapiScanResult.ScanDurationSeconds != nil {
+		metadata.ScanDuration = time.Duration(*oapiScanResult.ScanDurationSeconds * float64(time.Second))
+	}
+
+	triggers := []database.ScanTrigger{}
+	if oapiScanResult.TriggeredBy != nil {
+		for _, trigger := range *oapiScanResult.TriggeredBy {
+			triggers = append(triggers, database.ScanTrigger{
+				Namespace: trigger.Namespace,
+				Pod:       trigger.Pod,
+				Container: trigger.Container,
+			})
+		}
+	}
+
+	scanResult, created, err := s.scanService.UpsertScanResult(
+		r.Context(),
+		oapiScanResult.ImageId,
+		string(*oapiScanResult.Report),
+		metadata,
+		triggers,
+	)
 	if errors.Is(err, service.InvalidCycloneDXBOM) {
 		s.logger.Error(err, "PutScanResults")
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+		metrics.ScanFailuresTotal.WithLabelValues(metrics.InvalidReport).Inc()
+		s.Publish(events.Event{
+			Type:       events.ScanFailed,
+			ImageID:    oapiScanResult.ImageId,
+			Namespaces: s.eventNamespaces(r.Context(), oapiScanResult.ImageId),
+		})
+		writeProblem(w, r, invalidCycloneDX, err.Error())
 		return
 	} else if err != nil {
 		s.logger.Error(err, "PutScanResults")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		metrics.ScanFailuresTotal.WithLabelValues(metrics.UploadFailed).Inc()
+		writeProblem(w, r, internalError, "")
 		return
 	}
 
-	s.broadcastCh <- scanResult.ImageID
-	s.logger.Info("PutScanResults", "new imageId broadcasted", scanResult.ImageID)
+	// A concurrent upload with the same token may have spent it since it was
+	// verified, but then the report of the same Job was stored twice.
+	if err := s.uploadTokens.Use(r.Context(), *params.XUploadToken, oapiScanResult.ImageId); err != nil {
+		s.logger.Error(err, "PutScanResults", "imageId", oapiScanResult.ImageId)
+	}
+
+	metrics.ImagesScannedTotal.Inc()
+	if metadata.ScanDuration > 0 {
+		metrics.ScanDuration.Observe(metadata.ScanDuration.Seconds())
+	}
 
-	res := oapi.ScanResult{
-		ImageId: scanResult.ImageID,
-		Report:  json.RawMessage(scanResult.Report),
+	eventType := events.ScanResultUpdated
+	if created {
+		eventType = events.ScanResultCreated
 	}
 
+	s.Publish(events.Event{
+		Type:       eventType,
+		ImageID:    scanResult.ImageID,
+		Namespaces: s.eventNamespaces(r.Context(), scanResult.ImageID),
+		Summary:    &scanResult.VulnerabilitySummary,
+	})
+	s.logger.Info("PutScanResults", "event", eventType, "imageId", scanResult.ImageID)
+
+	res := toOapiScanResult(scanResult)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(res); err != nil {
@@ -177,31 +528,46 @@ func (s *Server) PutScanResults(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) DeleteScanResultsImageId(w http.ResponseWriter, r *http.Request, imageId string) {
 	defer observeDuration("DELETE", "/scan-results/{imageId}")()
-	if err := s.scanService.DeleteScanResult(imageId); err != nil {
+	// The namespaces of the ScanResult are needed by the filters of the
+	// subscribers.
+	namespaces := []string{}
+	_, err := s.scanService.GetScanResult(r.Context(), imageId)
+	if err == nil {
+		namespaces = s.eventNamespaces(r.Context(), imageId)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 		s.logger.Error(err, "DeleteScanResultsImageId")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeProblem(w, r, internalError, "")
 		return
 	}
 
+	if err := s.scanService.DeleteScanResult(r.Context(), imageId); err != nil {
+		s.logger.Error(err, "DeleteScanResultsImageId")
+		writeProblem(w, r, internalError, "")
+		return
+	}
+
+	s.Publish(events.Event{Type: events.ScanResultDeleted, ImageID: imageId, Namespaces: namespaces})
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (s *Server) GetScanResultsImageId(w http.ResponseWriter, r *http.Request, imageId string) {
 	defer observeDuration("GET", "/scan-results/{imageId}")()
-	scanResult, err := s.scanService.GetScanResult(imageId)
+	if !s.checkImageVisible(w, r, imageId, "GetScanResultsImageId") {
+		return
+	}
+
+	scanResult, err := s.scanService.GetScanResult(r.Context(), imageId)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		s.logger.Error(err, "GetScanResultsImageId")
-		http.Error(w, "Not Found", http.StatusNotFound)
+		writeProblem(w, r, notFound, fmt.Sprintf("no ScanResult of image %s", imageId))
+		return
 	} else if err != nil {
 		s.logger.Error(err, "GetScanResultsImageId")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeProblem(w, r, internalError, "")
 		return
 	}
 
-	res := oapi.ScanResult{
-		ImageId: scanResult.ImageID,
-		Report:  json.RawMessage(scanResult.Report),
-	}
+	res := toOapiScanResult(scanResult)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -209,3 +575,471 @@ func (s *Server) GetScanResultsImageId(w http.ResponseWriter, r *http.Request, i
 		s.logger.Error(err, "GetScanResultsImageId")
 	}
 }
+
+func (s *Server) GetVulnerabilitiesVulnerabilityIdImages(w http.ResponseWriter, r *http.Request, vulnerabilityId string) {
+	defer observeDuration("GET", "/vulnerabilities/{vulnerabilityId}/images")()
+	vulnerabilities, err := s.scanService.FindVulnerabilities(r.Context(), vulnerabilityId)
+	if err != nil {
+		s.logger.Error(err, "GetVulnerabilitiesVulnerabilityIdImages")
+		writeProblem(w, r, internalError, "")
+		return
+	}
+
+	res := []oapi.ImageMatch{}
+	indices := map[string]int{}
+	for _, vulnerability := range vulnerabilities {
+		i, ok := indices[vulnerability.ImageID]
+		if !ok {
+			i = len(res)
+			indices[vulnerability.ImageID] = i
+			res = append(res, oapi.ImageMatch{
+				ImageId:  vulnerability.ImageID,
+				Purls:    []string{},
+				Severity: &vulnerability.Severity,
+			})
+		}
+
+		if vulnerability.Purl != "" && !slices.Contains(res[i].Purls, vulnerability.Purl) {
+			res[i].Purls = append(res[i].Purls, vulnerability.Purl)
+		}
+
+		if service.SeverityRank(vulnerability.Severity) > service.SeverityRank(*res[i].Severity) {
+			res[i].Severity = &vulnerability.Severity
+		}
+	}
+
+	s.writeImageMatches(w, r, res, "GetVulnerabilitiesVulnerabilityIdImages")
+}
+
+func (s *Server) GetComponents(w http.ResponseWriter, r *http.Request, params oapi.GetComponentsParams) {
+	defer observeDuration("GET", "/components")()
+	components, err := s.scanService.FindComponents(r.Context(), params.Purl)
+	if err != nil {
+		s.logger.Error(err, "GetComponents")
+		writeProblem(w, r, internalError, "")
+		return
+	}
+
+	res := []oapi.ImageMatch{}
+	indices := map[string]int{}
+	for _, component := range components {
+		i, ok := indices[component.ImageID]
+		if !ok {
+			i = len(res)
+			indices[component.ImageID] = i
+			res = append(res, oapi.ImageMatch{
+				ImageId: component.ImageID,
+				Purls:   []string{},
+			})
+		}
+
+		if !slices.Contains(res[i].Purls, component.Purl) {
+			res[i].Purls = append(res[i].Purls, component.Purl)
+		}
+	}
+
+	s.writeImageMatches(w, r, res, "GetComponents")
+}
+
+// eventNamespaces returns the namespaces recorded for the image by the
+// reconciler, which decide who can see its events. The triggers of a report
+// are not trusted, as they are sent by the scan Job.
+func (s *Server) eventNamespaces(ctx context.Context, imageId string) []string {
+	recorded, err := s.scanService.ImageNamespaces(ctx, []string{imageId})
+	if err != nil {
+		s.logger.Error(err, "eventNamespaces", "imageId", imageId)
+		return []string{}
+	}
+
+	return append([]string{}, recorded[imageId]...)
+}
+
+// checkImageVisible responds with Not Found unless the caller can see the
+// image, in which case it returns true.
+func (s *Server) checkImageVisible(w http.ResponseWriter, r *http.Request, imageId string, handlerName string) bool {
+	v, err := s.visibility(r.Context())
+	if err != nil {
+		s.logger.Error(err, handlerName)
+		writeProblem(w, r, internalError, "")
+		return false
+	}
+
+	visible, err := s.visibleImages(r.Context(), v, []string{imageId})
+	if err != nil {
+		s.logger.Error(err, handlerName)
+		writeProblem(w, r, internalError, "")
+		return false
+	}
+
+	if !visible[imageId] {
+		writeProblem(w, r, notFound, fmt.Sprintf("no ScanResult of image %s", imageId))
+		return false
+	}
+
+	return true
+}
+
+// writeImageMatches completes the matches visible to the caller with the
+// workloads currently running the images in the visible namespaces and writes
+// them to the response.
+func (s *Server) writeImageMatches(w http.ResponseWriter, r *http.Request, matches []oapi.ImageMatch, handlerName string) {
+	v, err := s.visibility(r.Context())
+	if err != nil {
+		s.logger.Error(err, handlerName)
+		writeProblem(w, r, internalError, "")
+		return
+	}
+
+	imageIDs := []string{}
+	for _, match := range matches {
+		imageIDs = append(imageIDs, match.ImageId)
+	}
+
+	visible, err := s.visibleImages(r.Context(), v, imageIDs)
+	if err != nil {
+		s.logger.Error(err, handlerName)
+		writeProblem(w, r, internalError, "")
+		return
+	}
+
+	matches = slices.DeleteFunc(matches, func(match oapi.ImageMatch) bool {
+		return !visible[match.ImageId]
+	})
+	imageIDs = slices.DeleteFunc(imageIDs, func(imageID string) bool {
+		return !visible[imageID]
+	})
+
+	usages, err := s.workloadService.ListImageUsages(r.Context(), imageIDs)
+	if err != nil {
+		s.logger.Error(err, handlerName)
+		writeProblem(w, r, internalError, "")
+		return
+	}
+
+	for i := range matches {
+		matches[i].Workloads = []oapi.Workload{}
+		for _, usage := range usages[matches[i].ImageId] {
+			if !v.sees([]string{usage.Namespace}) {
+				continue
+			}
+
+			workload := oapi.Workload{
+				Namespace: usage.Namespace,
+				Pod:       usage.Pod,
+				Container: usage.Container,
+			}
+
+			if usage.WorkloadKind != "" {
+				workload.Kind = &usage.WorkloadKind
+				workload.Name = &usage.WorkloadName
+			}
+
+			matches[i].Workloads = append(matches[i].Workloads, workload)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(matches); err != nil {
+		s.logger.Error(err, handlerName)
+	}
+}
+
+func (s *Server) GetScanResultsImageIdScans(w http.ResponseWriter, r *http.Request, imageId string) {
+	defer observeDuration("GET", "/scan-results/{imageId}/scans")()
+	if !s.checkImageVisible(w, r, imageId, "GetScanResultsImageIdScans") {
+		return
+	}
+
+	scans, err := s.scanService.ListScans(r.Context(), imageId)
+	if err != nil {
+		s.logger.Error(err, "GetScanResultsImageIdScans")
+		writeProblem(w, r, internalError, "")
+		return
+	}
+
+	res := []oapi.Scan{}
+	for _, scan := range scans {
+		res = append(res, toOapiScan(scan))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		s.logger.Error(err, "GetScanResultsImageIdScans")
+	}
+}
+
+func (s *Server) GetScanResultsImageIdDiff(
+	w http.ResponseWriter,
+	r *http.Request,
+	imageId string,
+	params oapi.GetScanResultsImageIdDiffParams,
+) {
+	defer observeDuration("GET", "/scan-results/{imageId}/diff")()
+	if !s.checkImageVisible(w, r, imageId, "GetScanResultsImageIdDiff") {
+		return
+	}
+
+	fromId, ok := toScanId(params.From)
+	if !ok {
+		writeProblem(w, r, invalidParameter, "from must not be negative")
+		return
+	}
+
+	toId, ok := toScanId(params.To)
+	if !ok {
+		writeProblem(w, r, invalidParameter, "to must not be negative")
+		return
+	}
+
+	diff, err := s.scanService.DiffScans(r.Context(), imageId, fromId, toId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeProblem(w, r, notFound, fmt.Sprintf("no scan of image %s to compare", imageId))
+		return
+	} else if err != nil {
+		s.logger.Error(err, "GetScanResultsImageIdDiff")
+		writeProblem(w, r, internalError, "")
+		return
+	}
+
+	res := oapi.ScanDiff{
+		To:              toOapiScan(diff.To),
+		Introduced:      toOapiFindings(diff.Introduced),
+		Fixed:           toOapiFindings(diff.Fixed),
+		SeverityChanged: []oapi.Finding{},
+	}
+
+	if diff.From != nil {
+		from := toOapiScan(diff.From)
+		res.From = &from
+	}
+
+	for _, change := range diff.SeverityChanged {
+		finding := toOapiFindings([]service.Finding{change.Finding})[0]
+		finding.PreviousSeverity = &change.PreviousSeverity
+		res.SeverityChanged = append(res.SeverityChanged, finding)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		s.logger.Error(err, "GetScanResultsImageIdDiff")
+	}
+}
+
+func toScanId(param *int64) (*uint, bool) {
+	if param == nil {
+		return nil, true
+	}
+
+	if *param < 0 {
+		return nil, false
+	}
+
+	id := uint(*param)
+	return &id, true
+}
+
+// maxScanResultSize is the size limit of the uploaded ScanResults.
+const maxScanResultSize = 64 << 20
+
+// defaultLimit and maxLimit bound the size of the pages of ScanResults.
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+func toListScanResultsOptions(params oapi.GetScanResultsParams) (service.ListScanResultsOptions, error) {
+	options := service.ListScanResultsOptions{
+		Limit:        defaultLimit,
+		HasFix:       params.HasFix,
+		ScannedSince: params.ScannedSince,
+	}
+
+	if params.Limit != nil {
+		if *params.Limit < 1 || *params.Limit > maxLimit {
+			return options, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+
+		options.Limit = *params.Limit
+	}
+
+	if params.Cursor != nil {
+		options.Cursor = *params.Cursor
+	}
+
+	if params.Namespace != nil {
+		options.Namespace = *params.Namespace
+	}
+
+	if params.MinSeverity != nil {
+		if service.SeverityRank(string(*params.MinSeverity)) == 0 {
+			return options, fmt.Errorf("minSeverity %q is not supported", *params.MinSeverity)
+		}
+
+		options.MinSeverity = string(*params.MinSeverity)
+	}
+
+	if params.Repository != nil {
+		options.Repository = *params.Repository
+	}
+
+	sorts := map[oapi.GetScanResultsParamsSort]service.ScanResultSort{
+		oapi.ImageId:   service.SortByImageID,
+		oapi.CreatedAt: service.SortByCreatedAt,
+		oapi.UpdatedAt: service.SortByUpdatedAt,
+	}
+
+	if params.Sort != nil {
+		sort, ok := sorts[*params.Sort]
+		if !ok {
+			return options, fmt.Errorf("sort %q is not supported", *params.Sort)
+		}
+
+		options.Sort = sort
+	}
+
+	if params.Order != nil {
+		switch *params.Order {
+		case oapi.Asc:
+		case oapi.Desc:
+			options.Descending = true
+		default:
+			return options, fmt.Errorf("order %q is not supported", *params.Order)
+		}
+	}
+
+	if params.View != nil {
+		switch *params.View {
+		case oapi.Full:
+		case oapi.Summary:
+			options.WithoutReports = true
+		default:
+			return options, fmt.Errorf("view %q is not supported", *params.View)
+		}
+	}
+
+	return options, nil
+}
+
+func toOapiScanResult(scanResult *database.ScanResult) oapi.ScanResult {
+	scanDurationSeconds := scanResult.ScanDuration.Seconds()
+	triggeredBy := []oapi.ScanTrigger{}
+	for _, trigger := range scanResult.Triggers {
+		triggeredBy = append(triggeredBy, oapi.ScanTrigger{
+			Namespace: trigger.Namespace,
+			Pod:       trigger.Pod,
+			Container: trigger.Container,
+		})
+	}
+
+	var report *json.RawMessage
+	if scanResult.Report != "" {
+		raw := json.RawMessage(scanResult.Report)
+		report = &raw
+	}
+
+	summary := toOapiVulnerabilitySummary(&scanResult.VulnerabilitySummary)
+
+	return oapi.ScanResult{
+		ImageId:             scanResult.ImageID,
+		Report:              report,
+		CreatedAt:           &scanResult.CreatedAt,
+		UpdatedAt:           &scanResult.UpdatedAt,
+		ScannerName:         &scanResult.ScannerName,
+		ScannerVersion:      &scanResult.ScannerVersion,
+		DatabaseVersion:     &scanResult.DatabaseVersion,
+		ScanDurationSeconds: &scanDurationSeconds,
+		TriggeredBy:         &triggeredBy,
+		Summary:             &summary,
+	}
+}
+
+// encodeEvent encodes the event in the format requested by the subscriber. In
+// the legacy format only the quoted imageIds of the created and updated
+// ScanResults are sent, nil is returned for the other events.
+func encodeEvent(event events.Event, format oapi.GetSubscribeParamsFormat) ([]byte, error) {
+	if format == oapi.Legacy {
+		if event.Type != events.ScanResultCreated && event.Type != events.ScanResultUpdated {
+			return nil, nil
+		}
+
+		return json.Marshal(event.ImageID)
+	}
+
+	return json.Marshal(toOapiEvent(event))
+}
+
+// encodeServerSentEvent encodes the event as a Server-Sent Events message
+// identified by its ID.
+func encodeServerSentEvent(event events.Event) ([]byte, error) {
+	data, err := json.Marshal(toOapiEvent(event))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf("id: %s\ndata: %s\n\n", event.ID, data)), nil
+}
+
+func toOapiEvent(event events.Event) oapi.Event {
+	res := oapi.Event{
+		Version:    events.Version,
+		Type:       oapi.EventType(event.Type),
+		ImageId:    event.ImageID,
+		Namespaces: event.Namespaces,
+		Timestamp:  event.Timestamp,
+		Sequence:   int64(event.Sequence),
+	}
+
+	if res.Namespaces == nil {
+		res.Namespaces = []string{}
+	}
+
+	if event.Summary != nil {
+		summary := toOapiVulnerabilitySummary(event.Summary)
+		res.Summary = &summary
+	}
+
+	return res
+}
+
+func toOapiVulnerabilitySummary(summary *database.VulnerabilitySummary) oapi.VulnerabilitySummary {
+	return oapi.VulnerabilitySummary{
+		Critical:     summary.CriticalCount,
+		High:         summary.HighCount,
+		Medium:       summary.MediumCount,
+		Low:          summary.LowCount,
+		Info:         summary.InfoCount,
+		Unknown:      summary.UnknownCount,
+		Fixable:      summary.FixableCount,
+		MaxCvssScore: summary.MaxCVSSScore,
+		Components:   summary.ComponentCount,
+	}
+}
+
+func toOapiScan(scan *database.Scan) oapi.Scan {
+	return oapi.Scan{
+		Id:                  int64(scan.ID),
+		ImageId:             scan.ImageID,
+		CreatedAt:           scan.CreatedAt,
+		ScannerName:         scan.ScannerName,
+		ScannerVersion:      scan.ScannerVersion,
+		DatabaseVersion:     scan.DatabaseVersion,
+		ScanDurationSeconds: scan.ScanDuration.Seconds(),
+	}
+}
+
+func toOapiFindings(findings []service.Finding) []oapi.Finding {
+	res := []oapi.Finding{}
+	for _, finding := range findings {
+		res = append(res, oapi.Finding{
+			VulnerabilityId: finding.VulnerabilityID,
+			Aliases:         finding.Aliases,
+			Purl:            finding.Purl,
+			Severity:        finding.Severity,
+		})
+	}
+
+	return res
+}
@@ -0,0 +1,590 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/gorilla/websocket"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"github.com/kerezsiz42/scanner-operator2/internal/events"
+	"github.com/kerezsiz42/scanner-operator2/internal/oapi"
+	"github.com/kerezsiz42/scanner-operator2/internal/service"
+)
+
+func newTestServer(t *testing.T, options hubOptions) (*Server, string) {
+	t.Helper()
+	s := NewServer(nil, nil, nil, nil, events.NewMemoryBus().Member(), logr.Discard())
+	s.hub = newHub(options)
+	ts := httptest.NewServer(oapi.Handler(s))
+	t.Cleanup(ts.Close)
+
+	return s, "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func dialSubscribe(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// waitForSubscribers waits until the handlers registered the connections,
+// which happens after the handshake has completed on the client side.
+func waitForSubscribers(t *testing.T, s *Server, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.hub.len() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d subscribers, got %d", n, s.hub.len())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	const clients = 20
+	s, url := newTestServer(t, defaultHubOptions)
+	conns := []*websocket.Conn{}
+	for i := 0; i < clients; i++ {
+		conns = append(conns, dialSubscribe(t, url+"/subscribe"))
+	}
+
+	legacy := dialSubscribe(t, url+"/subscribe?format=legacy")
+	waitForSubscribers(t, s, clients+1)
+
+	published := []events.Type{events.ScanStarted, events.ScanResultCreated, events.ScanResultDeleted}
+	for _, eventType := range published {
+		s.Publish(events.Event{Type: eventType, ImageID: "alpine"})
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		wg.Add(1)
+		go func(c *websocket.Conn) {
+			defer wg.Done()
+			for i, eventType := range published {
+				event := oapi.Event{}
+				if err := c.ReadJSON(&event); err != nil {
+					t.Error(err)
+					return
+				}
+
+				if event.Version != events.Version || event.Sequence != int64(i+1) ||
+					event.Type != oapi.EventType(eventType) || event.ImageId != "alpine" {
+					t.Errorf("unexpected event: %+v", event)
+				}
+			}
+		}(c)
+	}
+
+	wg.Wait()
+
+	_, data, err := legacy.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var imageId string
+	if err := json.Unmarshal(data, &imageId); err != nil || imageId != "alpine" {
+		t.Errorf("unexpected legacy message: %s", data)
+	}
+
+	if _, _, err := websocket.DefaultDialer.Dial(url+"/subscribe?format=xml", nil); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
+
+func TestSubscribeShutdown(t *testing.T) {
+	s, url := newTestServer(t, defaultHubOptions)
+	conns := []*websocket.Conn{}
+	for i := 0; i < 10; i++ {
+		conns = append(conns, dialSubscribe(t, url+"/subscribe"))
+	}
+
+	waitForSubscribers(t, s, len(conns))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+
+	cancel()
+	<-done
+
+	for _, c := range conns {
+		_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, _, err := c.ReadMessage()
+		if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+			t.Errorf("expected the connection to be closed, got %v", err)
+		}
+	}
+
+	res, err := http.Get(strings.Replace(url, "ws", "http", 1) + "/subscribe")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected new subscribers to be rejected, got %d", res.StatusCode)
+	}
+}
+
+func TestSubscribeSlowClient(t *testing.T) {
+	s, url := newTestServer(t, hubOptions{bufferSize: 1, policy: disconnectSlowSubscribers})
+	c := dialSubscribe(t, url+"/subscribe")
+	waitForSubscribers(t, s, 1)
+
+	// The client does not read, so once the socket buffers are full the
+	// writer blocks and the hub has to drop the client instead of blocking
+	// the publisher.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for s.hub.len() > 0 {
+			s.Publish(events.Event{Type: events.ScanResultUpdated, ImageID: strings.Repeat("a", 4096)})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the slow client was not disconnected")
+	}
+
+	_ = c.Close()
+}
+
+// readServerSentEvent reads the next message of the stream, skipping the
+// comments and the fields without a value.
+func readServerSentEvent(t *testing.T, reader *bufio.Reader) map[string]string {
+	t.Helper()
+	fields := map[string]string{}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if len(fields) == 0 {
+				continue
+			}
+
+			return fields
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		name, value, _ := strings.Cut(line, ": ")
+		fields[name] = value
+	}
+}
+
+func TestEvents(t *testing.T) {
+	s, url := newTestServer(t, defaultHubOptions)
+	url = strings.Replace(url, "ws", "http", 1) + "/events"
+	s.Publish(events.Event{Type: events.ScanStarted, ImageID: "alpine"})
+	s.Publish(events.Event{Type: events.ScanResultCreated, ImageID: "alpine"})
+
+	get := func(lastEventID string) *bufio.Reader {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() { res.Body.Close() })
+		if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("unexpected response: %d %s", res.StatusCode, res.Header.Get("Content-Type"))
+		}
+
+		return bufio.NewReader(res.Body)
+	}
+
+	epoch := s.hub.epoch
+	resumed := get(epoch + "-1")
+	if fields := readServerSentEvent(t, resumed); fields["retry"] == "" {
+		t.Errorf("expected the reconnection delay first, got %v", fields)
+	}
+
+	fields := readServerSentEvent(t, resumed)
+	event := oapi.Event{}
+	if err := json.Unmarshal([]byte(fields["data"]), &event); err != nil {
+		t.Fatal(err)
+	}
+
+	if fields["id"] != epoch+"-2" || event.Sequence != 2 || event.Type != oapi.ScanResultCreated {
+		t.Errorf("expected the missed event, got %v", fields)
+	}
+
+	// The ids unknown to the server, like the ones sent before a restart,
+	// reset the client.
+	readers := []*bufio.Reader{resumed}
+	for _, lastEventID := range []string{epoch + "-100", "previous-2", "2", "abc"} {
+		reset := get(lastEventID)
+		readServerSentEvent(t, reset)
+		if fields := readServerSentEvent(t, reset); fields["event"] != "reset" {
+			t.Errorf("%s: expected a reset, got %v", lastEventID, fields)
+		}
+
+		readers = append(readers, reset)
+	}
+
+	waitForSubscribers(t, s, len(readers))
+	s.Publish(events.Event{Type: events.ScanResultDeleted, ImageID: "alpine"})
+	for _, reader := range readers {
+		if fields := readServerSentEvent(t, reader); fields["id"] != epoch+"-3" {
+			t.Errorf("expected the published event, got %v", fields)
+		}
+	}
+}
+
+func TestSubscribeFilter(t *testing.T) {
+	s, url := newTestServer(t, defaultHubOptions)
+	c := dialSubscribe(t, url+"/subscribe?namespace=team-a&type=scanStarted")
+	waitForSubscribers(t, s, 1)
+
+	s.Publish(events.Event{Type: events.ScanStarted, ImageID: "b", Namespaces: []string{"team-b"}})
+	s.Publish(events.Event{Type: events.ScanFailed, ImageID: "a", Namespaces: []string{"team-a"}})
+	s.Publish(events.Event{Type: events.ScanStarted, ImageID: "a", Namespaces: []string{"team-a"}})
+
+	event := oapi.Event{}
+	if err := c.ReadJSON(&event); err != nil {
+		t.Fatal(err)
+	}
+
+	if event.Sequence != 3 {
+		t.Errorf("expected only the third event to match, got %+v", event)
+	}
+
+	if err := c.WriteJSON(oapi.EventFilter{Namespaces: &[]string{"team-b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The filter is replaced asynchronously, so publish events matching either
+	// of the filters until the new one applies.
+	for event.ImageId != "b" {
+		s.Publish(events.Event{Type: events.ScanStarted, ImageID: "a", Namespaces: []string{"team-a"}})
+		s.Publish(events.Event{Type: events.ScanFailed, ImageID: "b", Namespaces: []string{"team-b"}})
+		if err := c.ReadJSON(&event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, _, err := websocket.DefaultDialer.Dial(url+"/subscribe?minSeverity=severe", nil); err == nil {
+		t.Error("expected an invalid filter to be rejected")
+	}
+}
+
+func TestSubscribeAcrossReplicas(t *testing.T) {
+	bus := events.NewMemoryBus()
+	replicas := []*Server{}
+	urls := []string{}
+	for i := 0; i < 2; i++ {
+		s := NewServer(nil, nil, nil, nil, bus.Member(), logr.Discard())
+		ts := httptest.NewServer(oapi.Handler(s))
+		t.Cleanup(ts.Close)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.Run(ctx)
+		}()
+
+		t.Cleanup(func() {
+			cancel()
+			<-done
+		})
+
+		replicas = append(replicas, s)
+		urls = append(urls, "ws"+strings.TrimPrefix(ts.URL, "http"))
+	}
+
+	c := dialSubscribe(t, urls[1]+"/subscribe")
+	waitForSubscribers(t, replicas[1], 1)
+
+	// The replicas start receiving from the bus asynchronously, so publish
+	// until the first event arrives.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+				replicas[0].Publish(events.Event{Type: events.ScanResultCreated, ImageID: "alpine"})
+			}
+		}
+	}()
+
+	event := oapi.Event{}
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := c.ReadJSON(&event); err != nil {
+		t.Fatal(err)
+	}
+
+	if event.ImageId != "alpine" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
+
+// fakeUploadTokens accepts the token "valid" once for the image alpine.
+type fakeUploadTokens struct {
+	used bool
+}
+
+func (f *fakeUploadTokens) Issue(id string, imageID string) (string, error) {
+	return "valid", nil
+}
+
+func (f *fakeUploadTokens) Verify(_ context.Context, token string, imageID string) error {
+	if token != "valid" {
+		return service.InvalidUploadToken
+	}
+
+	if imageID != "alpine" {
+		return service.UploadTokenImageMismatch
+	}
+
+	if f.used {
+		return service.ReusedUploadToken
+	}
+
+	return nil
+}
+
+func (f *fakeUploadTokens) Use(ctx context.Context, token string, imageID string) error {
+	if err := f.Verify(ctx, token, imageID); err != nil {
+		return err
+	}
+
+	f.used = true
+	return nil
+}
+
+func (f *fakeUploadTokens) PruneUsedTokens(_ context.Context) (int64, error) {
+	return 0, nil
+}
+
+func TestPutScanResultsUploadToken(t *testing.T) {
+	s := NewServer(nil, nil, &fakeUploadTokens{used: true}, nil, events.NewMemoryBus().Member(), logr.Discard())
+	handler := oapi.Handler(s)
+	for _, token := range []string{"", "invalid", "valid"} {
+		r := httptest.NewRequest(http.MethodPut, "/scan-results", strings.NewReader(`{"imageId":"alpine","report":{}}`))
+		if token != "" {
+			r.Header.Set("X-Upload-Token", token)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected the upload with token %q to be rejected, got %d", token, w.Code)
+		}
+	}
+}
+
+func TestPutScanResultsKeepsUploadTokenOfRejectedReport(t *testing.T) {
+	uploadTokens := &fakeUploadTokens{}
+	s := NewServer(fakeMissingScanService{}, nil, uploadTokens, nil, events.NewMemoryBus().Member(), logr.Discard())
+	handler := oapi.Handler(s)
+	r := httptest.NewRequest(http.MethodPut, "/scan-results", strings.NewReader(`{"imageId":"alpine","report":{}}`))
+	r.Header.Set("X-Upload-Token", "valid")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected the report to be rejected, got %d", w.Code)
+	}
+
+	if uploadTokens.used {
+		t.Error("expected the upload token of a rejected report to be left unused")
+	}
+}
+
+// recordingScanService records the metadata of the rejected reports.
+type recordingScanService struct {
+	fakeMissingScanService
+	metadata []database.ScanMetadata
+}
+
+func (s *recordingScanService) UpsertScanResult(
+	ctx context.Context,
+	imageId string,
+	report string,
+	metadata database.ScanMetadata,
+	triggers []database.ScanTrigger,
+) (*database.ScanResult, bool, error) {
+	s.metadata = append(s.metadata, metadata)
+	return s.fakeMissingScanService.UpsertScanResult(ctx, imageId, report, metadata, triggers)
+}
+
+func TestPutScanResultsScanner(t *testing.T) {
+	scanService := &recordingScanService{}
+	s := NewServer(scanService, nil, &fakeUploadTokens{}, nil, events.NewMemoryBus().Member(), logr.Discard())
+	handler := oapi.Handler(s)
+	for _, body := range []string{
+		`{"imageId":"alpine","report":{},"scannerName":"grype"}`,
+		`{"imageId":"alpine","report":{},"scannerVersion":"0.83.0"}`,
+		`{"imageId":"alpine","report":{},"scannerName":"grype","scannerVersion":"0.83.0"}`,
+	} {
+		r := httptest.NewRequest(http.MethodPut, "/scan-results", strings.NewReader(body))
+		r.Header.Set("X-Upload-Token", "valid")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	expected := []database.ScanMetadata{
+		{ScannerName: "grype"},
+		{ScannerVersion: "0.83.0"},
+		{ScannerName: "grype", ScannerVersion: "0.83.0"},
+	}
+	if !slices.Equal(scanService.metadata, expected) {
+		t.Errorf("expected the scanner name and version to be stored independently, got %+v", scanService.metadata)
+	}
+}
+
+// fakeMatchService finds libssl3 in alpine and debian, CVE-2023-0464 in both
+// and CVE-2022-48174 in debian only. The namespaces of the images are recorded
+// by fakeNamespaceService.
+type fakeMatchService struct {
+	fakeNamespaceService
+}
+
+func (fakeMatchService) FindComponents(_ context.Context, purl string) ([]*database.Component, error) {
+	if purl != "pkg:apk/alpine/libssl3" {
+		return nil, nil
+	}
+
+	return []*database.Component{
+		{ImageID: "alpine", Purl: "pkg:apk/alpine/libssl3@3.0.8-r0"},
+		{ImageID: "debian", Purl: "pkg:apk/alpine/libssl3@3.0.9-r0"},
+	}, nil
+}
+
+func (fakeMatchService) FindVulnerabilities(_ context.Context, vulnerabilityId string) ([]*database.Vulnerability, error) {
+	switch vulnerabilityId {
+	case "CVE-2023-0464":
+		return []*database.Vulnerability{
+			{ImageID: "alpine", Purl: "pkg:apk/alpine/libssl3@3.0.8-r0", Severity: "critical"},
+			{ImageID: "debian", Purl: "pkg:apk/alpine/libssl3@3.0.9-r0", Severity: "critical"},
+		}, nil
+	case "CVE-2022-48174":
+		return []*database.Vulnerability{
+			{ImageID: "debian", Purl: "pkg:apk/alpine/busybox@1.35.0-r29", Severity: "high"},
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// fakeWorkloads runs alpine in team-a and debian in team-b.
+type fakeWorkloads struct{}
+
+func (fakeWorkloads) ListImageUsages(_ context.Context, imageIDs []string) (map[string][]service.ImageUsage, error) {
+	return map[string][]service.ImageUsage{
+		"alpine": {{Namespace: "team-a", Pod: "web", Container: "nginx"}},
+		"debian": {{Namespace: "team-b", Pod: "db", Container: "postgres"}},
+	}, nil
+}
+
+// TestImageMatches covers the responses of the lookups, which are tested
+// against the database in the service package.
+func TestImageMatches(t *testing.T) {
+	authz := podAuthorizer{"admin": {""}, "team-a": {"team-a"}}
+	s := NewServer(fakeMatchService{}, fakeWorkloads{}, nil, authz, events.NewMemoryBus().Member(), logr.Discard())
+	handler := oapi.HandlerWithOptions(s, oapi.StdHTTPServerOptions{ErrorHandlerFunc: ParameterErrorHandler})
+	cases := []struct {
+		user     string
+		path     string
+		status   int
+		expected []string
+	}{
+		{"admin", "/components?purl=pkg:apk/alpine/libssl3", http.StatusOK, []string{
+			"alpine [pkg:apk/alpine/libssl3@3.0.8-r0] team-a/web/nginx",
+			"debian [pkg:apk/alpine/libssl3@3.0.9-r0] team-b/db/postgres",
+		}},
+		{"team-a", "/components?purl=pkg:apk/alpine/libssl3", http.StatusOK, []string{
+			"alpine [pkg:apk/alpine/libssl3@3.0.8-r0] team-a/web/nginx",
+		}},
+		{"admin", "/components?purl=pkg:npm/lodash", http.StatusOK, []string{}},
+		{"admin", "/components", http.StatusBadRequest, nil},
+		{"team-a", "/vulnerabilities/CVE-2023-0464/images", http.StatusOK, []string{
+			"alpine [pkg:apk/alpine/libssl3@3.0.8-r0] team-a/web/nginx",
+		}},
+		// A vulnerability of the hidden images only is not told apart from an
+		// unknown one.
+		{"team-a", "/vulnerabilities/CVE-2022-48174/images", http.StatusOK, []string{}},
+		{"team-a", "/vulnerabilities/CVE-2000-0001/images", http.StatusOK, []string{}},
+		{"admin", "/vulnerabilities/CVE-2022-48174/images", http.StatusOK, []string{
+			"debian [pkg:apk/alpine/busybox@1.35.0-r29] team-b/db/postgres",
+		}},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, c.path, nil)
+		r = r.WithContext(request.WithUser(r.Context(), &user.DefaultInfo{Name: c.user}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("%s %s: expected status %d, got %d", c.user, c.path, c.status, w.Code)
+			continue
+		}
+
+		if c.expected == nil {
+			continue
+		}
+
+		matches := []oapi.ImageMatch{}
+		if err := json.NewDecoder(w.Body).Decode(&matches); err != nil {
+			t.Fatal(err)
+		}
+
+		found := []string{}
+		for _, match := range matches {
+			result := fmt.Sprintf("%s %v", match.ImageId, match.Purls)
+			for _, workload := range match.Workloads {
+				result += fmt.Sprintf(" %s/%s/%s", workload.Namespace, workload.Pod, workload.Container)
+			}
+
+			found = append(found, result)
+		}
+
+		if !slices.Equal(found, c.expected) {
+			t.Errorf("%s %s: expected %v, got %v", c.user, c.path, c.expected, found)
+		}
+	}
+}
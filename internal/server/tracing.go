@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTracing starts a span for every request of the API, continuing the
+// trace of the client passed in the traceparent header, e.g. of the
+// reconciliation which created the scan Job uploading a report.
+func WithTracing(handler http.Handler) http.Handler {
+	return otelhttp.NewHandler(handler, "scanner-api")
+}
+
+// NameSpanAfterRoute names the span of the request after the route of its
+// operation, like "PUT /scan-results". It is an operation middleware, as the
+// route is only known once the request was matched.
+func NameSpanAfterRoute(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Pattern != "" {
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(r.Pattern)
+			span.SetAttributes(attribute.String("http.route", r.Pattern))
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// visibility is the set of namespaces whose images a caller can see. The
+// callers who can get the pods of every namespace see every image, the others
+// the ones recorded in a namespace they can get the pods of.
+type visibility struct {
+	all        bool
+	namespaces []string
+}
+
+var allNamespaces = &visibility{all: true}
+
+// visibilityCacheTTL is how long the visibility of a user is reused, which
+// saves listing the namespaces and authorizing each of them per request.
+const visibilityCacheTTL = 10 * time.Second
+
+// visibilityCache holds the visibility of the users for visibilityCacheTTL.
+type visibilityCache struct {
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]visibilityCacheEntry
+}
+
+type visibilityCacheEntry struct {
+	visibility *visibility
+	expiresAt  time.Time
+}
+
+func newVisibilityCache() *visibilityCache {
+	return &visibilityCache{
+		now:     time.Now,
+		entries: map[string]visibilityCacheEntry{},
+	}
+}
+
+// visibilityCacheKey identifies the user by everything the authorizer is
+// passed about it.
+func visibilityCacheKey(u user.Info) string {
+	return strings.Join(append([]string{u.GetName(), u.GetUID()}, u.GetGroups()...), "\x00")
+}
+
+func (c *visibilityCache) get(u user.Info) (*visibility, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[visibilityCacheKey(u)]
+	if !ok || !c.now().Before(entry.expiresAt) {
+		return nil, false
+	}
+
+	return entry.visibility, true
+}
+
+// set stores the visibility of the user, dropping the expired entries, so
+// that the cache only holds the users of the last visibilityCacheTTL.
+func (c *visibilityCache) set(u user.Info, v *visibility) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+
+	c.entries[visibilityCacheKey(u)] = visibilityCacheEntry{visibility: v, expiresAt: now.Add(visibilityCacheTTL)}
+}
+
+// sees reports whether an image recorded in the namespaces is visible.
+func (v *visibility) sees(namespaces []string) bool {
+	return v.all || slices.ContainsFunc(namespaces, func(namespace string) bool {
+		return slices.Contains(v.namespaces, namespace)
+	})
+}
+
+// visibleNamespaces returns nil if every namespace is visible, as expected by
+// ListScanResultsOptions.
+func (v *visibility) visibleNamespaces() []string {
+	if v.all {
+		return nil
+	}
+
+	return append([]string{}, v.namespaces...)
+}
+
+// visibility checks which of the namespaces recorded for the images the
+// caller can get the pods of. Every namespace is visible when the API is not
+// authorized. The result is cached per user for visibilityCacheTTL.
+func (s *Server) visibility(ctx context.Context) (*visibility, error) {
+	if s.authz == nil {
+		return allNamespaces, nil
+	}
+
+	u, ok := request.UserFrom(ctx)
+	if !ok {
+		return &visibility{}, nil
+	}
+
+	if v, ok := s.visibilities.get(u); ok {
+		return v, nil
+	}
+
+	v, err := s.authorizeNamespaces(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	s.visibilities.set(u, v)
+	return v, nil
+}
+
+// authorizeNamespaces checks the namespaces the user can get the pods of.
+func (s *Server) authorizeNamespaces(ctx context.Context, u user.Info) (*visibility, error) {
+	canGetPods := func(namespace string) (bool, error) {
+		decision, _, err := s.authz.Authorize(ctx, authorizer.AttributesRecord{
+			User:            u,
+			Verb:            "get",
+			Resource:        "pods",
+			Namespace:       namespace,
+			ResourceRequest: true,
+		})
+
+		return decision == authorizer.DecisionAllow, err
+	}
+
+	if all, err := canGetPods(""); err != nil {
+		return nil, fmt.Errorf("failed to authorize cluster-wide access: %w", err)
+	} else if all {
+		return allNamespaces, nil
+	}
+
+	namespaces, err := s.scanService.ListNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &visibility{namespaces: []string{}}
+	for _, namespace := range namespaces {
+		allowed, err := canGetPods(namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to authorize access to namespace %s: %w", namespace, err)
+		}
+
+		if allowed {
+			v.namespaces = append(v.namespaces, namespace)
+		}
+	}
+
+	return v, nil
+}
+
+// visibleImages returns the images of the caller among imageIds.
+func (s *Server) visibleImages(ctx context.Context, v *visibility, imageIds []string) (map[string]bool, error) {
+	visible := map[string]bool{}
+	if v.all {
+		for _, imageId := range imageIds {
+			visible[imageId] = true
+		}
+
+		return visible, nil
+	}
+
+	namespaces, err := s.scanService.ImageNamespaces(ctx, imageIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, imageId := range imageIds {
+		visible[imageId] = v.sees(namespaces[imageId])
+	}
+
+	return visible, nil
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/events"
+	"github.com/kerezsiz42/scanner-operator2/internal/service"
+)
+
+// fakeNamespaceService records alpine in team-a and debian in team-b.
+type fakeNamespaceService struct {
+	service.ScanServiceInterface
+}
+
+func (fakeNamespaceService) ListNamespaces(_ context.Context) ([]string, error) {
+	return []string{"team-a", "team-b"}, nil
+}
+
+func (fakeNamespaceService) ImageNamespaces(_ context.Context, imageIds []string) (map[string][]string, error) {
+	return map[string][]string{"alpine": {"team-a"}, "debian": {"team-b"}}, nil
+}
+
+// podAuthorizer lets the users get the pods of the namespaces listed for
+// them, where the empty namespace stands for every namespace.
+type podAuthorizer map[string][]string
+
+func (a podAuthorizer) Authorize(ctx context.Context, attributes authorizer.Attributes) (authorizer.Decision, string, error) {
+	if attributes.GetVerb() == "get" && attributes.GetResource() == "pods" &&
+		slices.Contains(a[attributes.GetUser().GetName()], attributes.GetNamespace()) {
+		return authorizer.DecisionAllow, "", nil
+	}
+
+	return authorizer.DecisionNoOpinion, "", nil
+}
+
+func TestVisibility(t *testing.T) {
+	authz := podAuthorizer{"admin": {""}, "team-a": {"team-a"}}
+	s := NewServer(fakeNamespaceService{}, nil, nil, authz, events.NewMemoryBus().Member(), logr.Discard())
+	cases := []struct {
+		user     string
+		expected map[string]bool
+	}{
+		{"admin", map[string]bool{"alpine": true, "debian": true}},
+		{"team-a", map[string]bool{"alpine": true, "debian": false}},
+		{"team-c", map[string]bool{"alpine": false, "debian": false}},
+	}
+
+	for _, c := range cases {
+		ctx := request.WithUser(context.Background(), &user.DefaultInfo{Name: c.user})
+		v, err := s.visibility(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		visible, err := s.visibleImages(context.Background(), v, []string{"alpine", "debian"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for imageId, expected := range c.expected {
+			if visible[imageId] != expected {
+				t.Errorf("%s: expected visibility of %s to be %t", c.user, imageId, expected)
+			}
+
+			filter := &eventFilter{visibility: v}
+			namespace := map[string]string{"alpine": "team-a", "debian": "team-b"}[imageId]
+			if filter.matches(events.Event{ImageID: imageId, Namespaces: []string{namespace}}) != expected {
+				t.Errorf("%s: expected the events of %s to match %t", c.user, imageId, expected)
+			}
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/scan-results/debian", nil)
+	r = r.WithContext(request.WithUser(r.Context(), &user.DefaultInfo{Name: "team-a"}))
+	w := httptest.NewRecorder()
+	if s.checkImageVisible(w, r, "debian", "TestVisibility") || w.Code != http.StatusNotFound {
+		t.Errorf("expected the image of another namespace to be not found, got %d", w.Code)
+	}
+}
+
+// countingAuthorizer counts the decisions of the wrapped authorizer.
+type countingAuthorizer struct {
+	authorizer.Authorizer
+	count int
+}
+
+func (a *countingAuthorizer) Authorize(ctx context.Context, attributes authorizer.Attributes) (authorizer.Decision, string, error) {
+	a.count++
+	return a.Authorizer.Authorize(ctx, attributes)
+}
+
+func TestVisibilityIsCached(t *testing.T) {
+	authz := &countingAuthorizer{Authorizer: podAuthorizer{"team-a": {"team-a"}}}
+	s := NewServer(fakeNamespaceService{}, nil, nil, authz, events.NewMemoryBus().Member(), logr.Discard())
+	now := time.Now()
+	s.visibilities.now = func() time.Time { return now }
+
+	visibilityOf := func(name string) *visibility {
+		t.Helper()
+		v, err := s.visibility(request.WithUser(context.Background(), &user.DefaultInfo{Name: name}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return v
+	}
+
+	// The cluster-wide access and each of the two namespaces are authorized.
+	if v := visibilityOf("team-a"); !slices.Equal(v.namespaces, []string{"team-a"}) || authz.count != 3 {
+		t.Fatalf("expected team-a to be authorized once per namespace, got %v after %d decisions", v.namespaces, authz.count)
+	}
+
+	if v := visibilityOf("team-a"); !slices.Equal(v.namespaces, []string{"team-a"}) || authz.count != 3 {
+		t.Errorf("expected the visibility of team-a to be cached, got %v after %d decisions", v.namespaces, authz.count)
+	}
+
+	if visibilityOf("team-b"); authz.count != 6 {
+		t.Errorf("expected the visibility of team-b to be authorized, got %d decisions", authz.count)
+	}
+
+	now = now.Add(visibilityCacheTTL)
+	if visibilityOf("team-a"); authz.count != 9 {
+		t.Errorf("expected the visibility of team-a to expire, got %d decisions", authz.count)
+	}
+
+	if len(s.visibilities.entries) != 1 {
+		t.Errorf("expected the expired visibility of team-b to be dropped, got %d entries", len(s.visibilities.entries))
+	}
+}
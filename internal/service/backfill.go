@@ -0,0 +1,273 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	cyclonedx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"github.com/kerezsiz42/scanner-operator2/internal/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const backfillBatchSize = 100
+
+// Backfills returns the data migrations run together with the schema migration
+// of the same version. The search index, created by migration 2, is built by
+// migration 8 together with the fixable flags. The backfills work with the
+// rows as of their migration instead of the models, which may have columns
+// added by later migrations.
+func Backfills(reports storage.ReportStore) map[int]database.BackfillFunc {
+	return map[int]database.BackfillFunc{
+		5: backfillReports,
+		8: forEachReport(reports, backfillIndex),
+		9: forEachReport(reports, backfillSummary),
+	}
+}
+
+// legacyScanResult and legacyScan are the rows of scan_results and scans
+// from before the reports were moved to their own table.
+type legacyScanResult struct {
+	ImageID string `gorm:"primarykey"`
+	Report  string
+}
+
+func (legacyScanResult) TableName() string {
+	return "scan_results"
+}
+
+type legacyScan struct {
+	ID     uint `gorm:"primarykey"`
+	Report string
+}
+
+func (legacyScan) TableName() string {
+	return "scans"
+}
+
+// legacyReport is a row of reports from before the reports were moved to the
+// report store.
+type legacyReport struct {
+	Hash           string `gorm:"primarykey"`
+	Data           []byte
+	Size           int64
+	CompressedSize int64
+	CreatedAt      time.Time
+}
+
+func (legacyReport) TableName() string {
+	return "reports"
+}
+
+func storeLegacyReport(tx *gorm.DB, report string) (string, error) {
+	metadata, data, err := compressReport(report)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&legacyReport{
+		Hash:           metadata.Hash,
+		Data:           data,
+		Size:           metadata.Size,
+		CompressedSize: metadata.CompressedSize,
+		CreatedAt:      metadata.CreatedAt,
+	}).Error
+	if err != nil {
+		return "", err
+	}
+
+	return metadata.Hash, nil
+}
+
+// backfillReports moves the inline reports of ScanResults and Scans to the
+// reports table, from which migration 7 moves them to report_blobs.
+func backfillReports(tx *gorm.DB) error {
+	scanResults := []legacyScanResult{}
+	err := tx.Select("image_id", "report").FindInBatches(&scanResults, backfillBatchSize, func(batch *gorm.DB, _ int) error {
+		for _, scanResult := range scanResults {
+			hash, err := storeLegacyReport(tx, scanResult.Report)
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Model(&scanResult).Update("report_hash", hash).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	scans := []legacyScan{}
+	return tx.Select("id", "report").FindInBatches(&scans, backfillBatchSize, func(batch *gorm.DB, _ int) error {
+		for _, scan := range scans {
+			hash, err := storeLegacyReport(tx, scan.Report)
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Model(&scan).Update("report_hash", hash).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}).Error
+}
+
+// storedScanResult is a row of scan_results from after the reports were moved
+// to their own table by migration 5.
+type storedScanResult struct {
+	ImageID    string `gorm:"primarykey"`
+	ReportHash string
+}
+
+func (storedScanResult) TableName() string {
+	return "scan_results"
+}
+
+// indexedComponent and indexedVulnerability are the rows of the search index
+// as of migration 8.
+type indexedComponent struct {
+	ID       uint `gorm:"primarykey"`
+	ImageID  string
+	Name     string
+	Version  string
+	Purl     string
+	PurlBase string
+}
+
+func (indexedComponent) TableName() string {
+	return "components"
+}
+
+type indexedVulnerability struct {
+	ID              uint `gorm:"primarykey"`
+	ImageID         string
+	VulnerabilityID string
+	Severity        string
+	Purl            string
+	Fixable         bool
+}
+
+func (indexedVulnerability) TableName() string {
+	return "vulnerabilities"
+}
+
+// backfillIndex replaces the search index of the image like replaceIndex.
+func backfillIndex(tx *gorm.DB, imageId string, bom *cyclonedx.BOM) error {
+	if err := tx.Where("image_id = ?", imageId).Delete(&indexedComponent{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("image_id = ?", imageId).Delete(&indexedVulnerability{}).Error; err != nil {
+		return err
+	}
+
+	components, vulnerabilities := indexBOM(imageId, bom)
+	rows := []indexedComponent{}
+	for _, component := range components {
+		rows = append(rows, indexedComponent{
+			ImageID:  component.ImageID,
+			Name:     component.Name,
+			Version:  component.Version,
+			Purl:     component.Purl,
+			PurlBase: component.PurlBase,
+		})
+	}
+
+	if len(rows) > 0 {
+		if err := tx.CreateInBatches(rows, indexBatchSize).Error; err != nil {
+			return err
+		}
+	}
+
+	vulnerabilityRows := []indexedVulnerability{}
+	for _, vulnerability := range vulnerabilities {
+		vulnerabilityRows = append(vulnerabilityRows, indexedVulnerability{
+			ImageID:         vulnerability.ImageID,
+			VulnerabilityID: vulnerability.VulnerabilityID,
+			Severity:        vulnerability.Severity,
+			Purl:            vulnerability.Purl,
+			Fixable:         vulnerability.Fixable,
+		})
+	}
+
+	if len(vulnerabilityRows) > 0 {
+		if err := tx.CreateInBatches(vulnerabilityRows, indexBatchSize).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backfillSummary sets the summary columns added by migration 9 like
+// updateSummary.
+func backfillSummary(tx *gorm.DB, imageId string, bom *cyclonedx.BOM) error {
+	summary := summarizeBOM(bom)
+	return tx.Model(&storedScanResult{}).Where("image_id = ?", imageId).UpdateColumns(map[string]any{
+		"critical_count":  summary.CriticalCount,
+		"high_count":      summary.HighCount,
+		"medium_count":    summary.MediumCount,
+		"low_count":       summary.LowCount,
+		"info_count":      summary.InfoCount,
+		"unknown_count":   summary.UnknownCount,
+		"fixable_count":   summary.FixableCount,
+		"max_cvss_score":  summary.MaxCVSSScore,
+		"component_count": summary.ComponentCount,
+	}).Error
+}
+
+// forEachReport returns a backfill calling fn with the report of every
+// ScanResult. Reports are looked up in the database within the migration
+// transaction first, and in the report store otherwise. Reports which are not
+// valid CycloneDX BOMs are skipped.
+func forEachReport(
+	reports storage.ReportStore,
+	fn func(tx *gorm.DB, imageId string, bom *cyclonedx.BOM) error,
+) database.BackfillFunc {
+	return func(tx *gorm.DB) error {
+		reportStore := storage.NewFallbackReportStore(storage.NewDatabaseReportStore(tx), reports)
+		scanResults := []storedScanResult{}
+		return tx.Select("image_id", "report_hash").FindInBatches(&scanResults, backfillBatchSize, func(batch *gorm.DB, _ int) error {
+			hashes := []string{}
+			for _, scanResult := range scanResults {
+				hashes = append(hashes, scanResult.ReportHash)
+			}
+
+			compressed, err := reportStore.Load(tx.Statement.Context, hashes)
+			if err != nil {
+				return err
+			}
+
+			for _, scanResult := range scanResults {
+				data, ok := compressed[scanResult.ReportHash]
+				if !ok {
+					continue
+				}
+
+				report, err := decompressReport(data)
+				if err != nil {
+					return err
+				}
+
+				bom := cyclonedx.BOM{}
+				decoder := cyclonedx.NewBOMDecoder(strings.NewReader(report), cyclonedx.BOMFileFormatJSON)
+				if err := decoder.Decode(&bom); err != nil {
+					continue
+				}
+
+				if err := fn(tx, scanResult.ImageID, &bom); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}).Error
+	}
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+)
+
+func TestBackfillsRebuildIndexAndSummary(t *testing.T) {
+	s := newTestScanService(t)
+	report, _ := readTestBOM(t)
+	if _, _, err := s.UpsertScanResult(context.Background(), "alpine", report, database.ScanMetadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ScanResult is left as it was before migrations 8 and 9.
+	if err := deleteIndex(s.db, "alpine"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.db.Model(&database.ScanResult{}).Where("image_id = ?", "alpine").
+		Select(summaryColumns).UpdateColumns(database.ScanResult{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	backfills := Backfills(s.reports)
+	for _, version := range []int{8, 9} {
+		if err := backfills[version](s.db.WithContext(context.Background())); err != nil {
+			t.Fatalf("backfill %d: %v", version, err)
+		}
+	}
+
+	vulnerabilities, err := s.FindVulnerabilities(context.Background(), "GHSA-xxxx-yyyy-zzzz")
+	if err != nil || len(vulnerabilities) != 1 || !vulnerabilities[0].Fixable {
+		t.Errorf("expected the fixable vulnerability to be indexed, got %v (%v)", vulnerabilities, err)
+	}
+
+	scanResult, err := s.GetScanResult(context.Background(), "alpine")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scanResult.CriticalCount != 1 || scanResult.ComponentCount != 2 {
+		t.Errorf("expected the summary to be backfilled, got %+v", scanResult.VulnerabilitySummary)
+	}
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"strings"
+
+	cyclonedx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+)
+
+var severityRanks = map[cyclonedx.Severity]int{
+	cyclonedx.SeverityNone:     1,
+	cyclonedx.SeverityInfo:     2,
+	cyclonedx.SeverityLow:      3,
+	cyclonedx.SeverityMedium:   4,
+	cyclonedx.SeverityHigh:     5,
+	cyclonedx.SeverityCritical: 6,
+}
+
+// SeverityRank orders severities from unknown (0) to critical (6).
+func SeverityRank(severity string) int {
+	return severityRanks[cyclonedx.Severity(severity)]
+}
+
+// NormalizePurl strips the qualifiers and the subpath from a package URL and
+// returns it together with its versionless form.
+func NormalizePurl(purl string) (string, string) {
+	if i := strings.IndexAny(purl, "?#"); i != -1 {
+		purl = purl[:i]
+	}
+
+	base := purl
+	if i := strings.LastIndex(purl, "@"); i != -1 {
+		base = purl[:i]
+	}
+
+	return purl, base
+}
+
+func highestSeverity(ratings *[]cyclonedx.VulnerabilityRating) cyclonedx.Severity {
+	severity := cyclonedx.SeverityUnknown
+	if ratings == nil {
+		return severity
+	}
+
+	for _, rating := range *ratings {
+		if severityRanks[rating.Severity] > severityRanks[severity] {
+			severity = rating.Severity
+		}
+	}
+
+	return severity
+}
+
+func flattenComponents(components *[]cyclonedx.Component) []cyclonedx.Component {
+	if components == nil {
+		return nil
+	}
+
+	res := []cyclonedx.Component{}
+	for _, component := range *components {
+		res = append(res, component)
+		res = append(res, flattenComponents(component.Components)...)
+	}
+
+	return res
+}
+
+// Finding is a vulnerability affecting a single component of an image.
+// Aliases are the other identifiers the vulnerability is known by.
+type Finding struct {
+	VulnerabilityID string
+	Aliases         []string
+	Purl            string
+	Severity        string
+	Fixable         bool
+}
+
+func componentPurls(bom *cyclonedx.BOM) map[string]string {
+	purls := map[string]string{}
+	for _, component := range flattenComponents(bom.Components) {
+		if component.PackageURL != "" {
+			purls[component.BOMRef], _ = NormalizePurl(component.PackageURL)
+		}
+	}
+
+	return purls
+}
+
+// findingsFromBOM returns a Finding for every vulnerability and affected
+// component pair of a BOM.
+func findingsFromBOM(bom *cyclonedx.BOM) []Finding {
+	findings := []Finding{}
+	if bom.Vulnerabilities == nil {
+		return findings
+	}
+
+	purls := componentPurls(bom)
+	for _, vulnerability := range *bom.Vulnerabilities {
+		aliases := []string{}
+		if vulnerability.References != nil {
+			for _, reference := range *vulnerability.References {
+				if reference.ID != "" && reference.ID != vulnerability.ID {
+					aliases = append(aliases, reference.ID)
+				}
+			}
+		}
+
+		affected := []string{}
+		if vulnerability.Affects != nil {
+			for _, affects := range *vulnerability.Affects {
+				if purl, ok := purls[affects.Ref]; ok {
+					affected = append(affected, purl)
+				}
+			}
+		}
+
+		if len(affected) == 0 {
+			affected = append(affected, "")
+		}
+
+		severity := highestSeverity(vulnerability.Ratings)
+		for _, purl := range affected {
+			findings = append(findings, Finding{
+				VulnerabilityID: vulnerability.ID,
+				Aliases:         aliases,
+				Purl:            purl,
+				Severity:        string(severity),
+				Fixable:         vulnerability.Recommendation != "",
+			})
+		}
+	}
+
+	return findings
+}
+
+// indexBOM extracts the searchable components and vulnerabilities of a BOM.
+func indexBOM(imageId string, bom *cyclonedx.BOM) ([]database.Component, []database.Vulnerability) {
+	components := []database.Component{}
+	for _, component := range flattenComponents(bom.Components) {
+		if component.PackageURL == "" {
+			continue
+		}
+
+		purl, base := NormalizePurl(component.PackageURL)
+		components = append(components, database.Component{
+			ImageID:  imageId,
+			Name:     component.Name,
+			Version:  component.Version,
+			Purl:     purl,
+			PurlBase: base,
+		})
+	}
+
+	vulnerabilities := []database.Vulnerability{}
+	for _, finding := range findingsFromBOM(bom) {
+		for _, id := range append([]string{finding.VulnerabilityID}, finding.Aliases...) {
+			vulnerabilities = append(vulnerabilities, database.Vulnerability{
+				ImageID:         imageId,
+				VulnerabilityID: id,
+				Severity:        finding.Severity,
+				Purl:            finding.Purl,
+				Fixable:         finding.Fixable,
+			})
+		}
+	}
+
+	return components, vulnerabilities
+}
+
+// summarizeBOM counts the findings of a BOM by severity. Only CVSS ratings
+// are taken into account for the highest score.
+func summarizeBOM(bom *cyclonedx.BOM) database.VulnerabilitySummary {
+	summary := database.VulnerabilitySummary{
+		ComponentCount: len(flattenComponents(bom.Components)),
+	}
+
+	for _, finding := range findingsFromBOM(bom) {
+		switch cyclonedx.Severity(finding.Severity) {
+		case cyclonedx.SeverityCritical:
+			summary.CriticalCount++
+		case cyclonedx.SeverityHigh:
+			summary.HighCount++
+		case cyclonedx.SeverityMedium:
+			summary.MediumCount++
+		case cyclonedx.SeverityLow:
+			summary.LowCount++
+		case cyclonedx.SeverityInfo, cyclonedx.SeverityNone:
+			summary.InfoCount++
+		default:
+			summary.UnknownCount++
+		}
+
+		if finding.Fixable {
+			summary.FixableCount++
+		}
+	}
+
+	if bom.Vulnerabilities != nil {
+		for _, vulnerability := range *bom.Vulnerabilities {
+			if vulnerability.Ratings == nil {
+				continue
+			}
+
+			for _, rating := range *vulnerability.Ratings {
+				isCVSS := strings.HasPrefix(string(rating.Method), "CVSS")
+				if isCVSS && rating.Score != nil && *rating.Score > summary.MaxCVSSScore {
+					summary.MaxCVSSScore = *rating.Score
+				}
+			}
+		}
+	}
+
+	return summary
+}
+
+// scannerFromBOM returns the name and version of the tool which produced the
+// BOM, as recorded in its metadata.
+func scannerFromBOM(bom *cyclonedx.BOM) (string, string) {
+	if bom.Metadata == nil || bom.Metadata.Tools == nil {
+		return "", ""
+	}
+
+	if components := bom.Metadata.Tools.Components; components != nil && len(*components) > 0 {
+		return (*components)[0].Name, (*components)[0].Version
+	}
+
+	if tools := bom.Metadata.Tools.Tools; tools != nil && len(*tools) > 0 {
+		return (*tools)[0].Name, (*tools)[0].Version
+	}
+
+	return "", ""
+}
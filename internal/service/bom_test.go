@@ -0,0 +1,79 @@
+package service
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	cyclonedx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+)
+
+func readTestBOM(t testing.TB) (string, *cyclonedx.BOM) {
+	t.Helper()
+	report, err := os.ReadFile("testdata/bom.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bom := &cyclonedx.BOM{}
+	if err := cyclonedx.NewBOMDecoder(bytes.NewReader(report), cyclonedx.BOMFileFormatJSON).Decode(bom); err != nil {
+		t.Fatal(err)
+	}
+
+	return string(report), bom
+}
+
+func TestNormalizePurl(t *testing.T) {
+	tests := []struct {
+		purl string
+		want string
+		base string
+	}{
+		{"pkg:apk/alpine/libssl3@3.0.8-r0?arch=x86_64#sub", "pkg:apk/alpine/libssl3@3.0.8-r0", "pkg:apk/alpine/libssl3"},
+		{"pkg:npm/%40angular/core@1.0.0", "pkg:npm/%40angular/core@1.0.0", "pkg:npm/%40angular/core"},
+		{"pkg:npm/lodash", "pkg:npm/lodash", "pkg:npm/lodash"},
+	}
+
+	for _, tt := range tests {
+		purl, base := NormalizePurl(tt.purl)
+		if purl != tt.want || base != tt.base {
+			t.Errorf("NormalizePurl(%q) = %q, %q, want %q, %q", tt.purl, purl, base, tt.want, tt.base)
+		}
+	}
+}
+
+func TestIndexBOM(t *testing.T) {
+	_, bom := readTestBOM(t)
+	components, vulnerabilities := indexBOM("alpine", bom)
+
+	if len(components) != 2 {
+		t.Fatalf("expected 2 components, got %d", len(components))
+	}
+
+	// CVE-2023-0464 is also indexed under its GHSA alias.
+	if len(vulnerabilities) != 3 {
+		t.Fatalf("expected 3 vulnerabilities, got %d", len(vulnerabilities))
+	}
+
+	v := vulnerabilities[1]
+	if v.VulnerabilityID != "GHSA-xxxx-yyyy-zzzz" || v.Severity != "high" || v.Purl != "pkg:apk/alpine/libssl3@3.0.8-r0" {
+		t.Errorf("unexpected vulnerability: %+v", v)
+	}
+}
+
+func TestSummarizeBOM(t *testing.T) {
+	_, bom := readTestBOM(t)
+	summary := summarizeBOM(bom)
+	want := database.VulnerabilitySummary{
+		CriticalCount:  1,
+		HighCount:      1,
+		FixableCount:   1,
+		MaxCVSSScore:   9.8,
+		ComponentCount: 2,
+	}
+
+	if summary != want {
+		t.Errorf("summarizeBOM() = %+v, want %+v", summary, want)
+	}
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	cyclonedx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"gorm.io/gorm"
+)
+
+// SeverityChange is a Finding present in both scans with a different severity.
+type SeverityChange struct {
+	Finding
+	PreviousSeverity string
+}
+
+// ScanDiff describes how the findings of an image changed between two scans.
+// From is nil when the diff is calculated against the first scan of the image.
+type ScanDiff struct {
+	From            *database.Scan
+	To              *database.Scan
+	Introduced      []Finding
+	Fixed           []Finding
+	SeverityChanged []SeverityChange
+}
+
+// ListScans returns the scans of an image from the newest to the oldest
+// without their reports.
+func (s *ScanService) ListScans(ctx context.Context, imageId string) ([]*database.Scan, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	scans := []*database.Scan{}
+	res := s.db.WithContext(ctx).Where("image_id = ?", imageId).Order("id DESC").Find(&scans)
+	if res.Error != nil {
+		return nil, fmt.Errorf("error while listing Scans: %w", res.Error)
+	}
+
+	return scans, nil
+}
+
+// DiffScans compares two scans of an image. If toId is nil the latest scan is
+// used, and if fromId is nil the scan preceding the target one is used.
+func (s *ScanService) DiffScans(ctx context.Context, imageId string, fromId *uint, toId *uint) (*ScanDiff, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	db := s.db.WithContext(ctx)
+	to := database.Scan{}
+	query := db.Where("image_id = ?", imageId)
+	if toId != nil {
+		query = query.Where("id = ?", *toId)
+	}
+
+	if err := query.Order("id DESC").First(&to).Error; err != nil {
+		return nil, fmt.Errorf("error while getting Scan: %w", err)
+	}
+
+	var from *database.Scan
+	query = db.Where("image_id = ?", imageId)
+	if fromId != nil {
+		query = query.Where("id = ?", *fromId)
+	} else {
+		query = query.Where("id < ?", to.ID)
+	}
+
+	previous := database.Scan{}
+	err := query.Order("id DESC").First(&previous).Error
+	if err == nil {
+		from = &previous
+	} else if fromId != nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error while getting Scan: %w", err)
+	}
+
+	hashes := []string{to.ReportHash}
+	if from != nil {
+		hashes = append(hashes, from.ReportHash)
+	}
+
+	reports, err := s.loadReports(ctx, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting Scan: %w", err)
+	}
+
+	to.Report = reports[to.ReportHash]
+	toFindings, err := decodeFindings(to.Report)
+	if err != nil {
+		return nil, err
+	}
+
+	fromFindings := map[[2]string]Finding{}
+	if from != nil {
+		from.Report = reports[from.ReportHash]
+		if fromFindings, err = decodeFindings(from.Report); err != nil {
+			return nil, err
+		}
+	}
+
+	diff := &ScanDiff{
+		From:            from,
+		To:              &to,
+		Introduced:      []Finding{},
+		Fixed:           []Finding{},
+		SeverityChanged: []SeverityChange{},
+	}
+
+	for key, finding := range toFindings {
+		previous, ok := fromFindings[key]
+		if !ok {
+			diff.Introduced = append(diff.Introduced, finding)
+		} else if previous.Severity != finding.Severity {
+			diff.SeverityChanged = append(diff.SeverityChanged, SeverityChange{
+				Finding:          finding,
+				PreviousSeverity: previous.Severity,
+			})
+		}
+	}
+
+	for key, finding := range fromFindings {
+		if _, ok := toFindings[key]; !ok {
+			diff.Fixed = append(diff.Fixed, finding)
+		}
+	}
+
+	slices.SortFunc(diff.Introduced, compareFindings)
+	slices.SortFunc(diff.Fixed, compareFindings)
+	slices.SortFunc(diff.SeverityChanged, func(a, b SeverityChange) int {
+		return compareFindings(a.Finding, b.Finding)
+	})
+
+	return diff, nil
+}
+
+// decodeFindings returns the findings of the report by their vulnerability
+// and versionless package URL, so that a vulnerability which is still present
+// after upgrading its package is neither fixed nor introduced.
+func decodeFindings(report string) (map[[2]string]Finding, error) {
+	bom := cyclonedx.BOM{}
+	decoder := cyclonedx.NewBOMDecoder(strings.NewReader(report), cyclonedx.BOMFileFormatJSON)
+	if err := decoder.Decode(&bom); err != nil {
+		return nil, fmt.Errorf("%w: %w", InvalidCycloneDXBOM, err)
+	}
+
+	findings := map[[2]string]Finding{}
+	for _, finding := range findingsFromBOM(&bom) {
+		_, base := NormalizePurl(finding.Purl)
+		findings[[2]string{finding.VulnerabilityID, base}] = finding
+	}
+
+	return findings, nil
+}
+
+func compareFindings(a, b Finding) int {
+	return cmp.Or(
+		strings.Compare(a.VulnerabilityID, b.VulnerabilityID),
+		strings.Compare(a.Purl, b.Purl),
+	)
+}
@@ -2,14 +2,23 @@ package service
 
 import (
 	"bytes"
+	"context"
 	_ "embed"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"text/template"
+	"time"
 
+	"github.com/kerezsiz42/scanner-operator2/internal/tracing"
 	"github.com/kerezsiz42/scanner-operator2/internal/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
@@ -18,57 +27,197 @@ import (
 //go:embed job.template.yaml
 var JobTemplateYAML string
 
+// ImageIDAnnotation is the annotation of the Jobs holding the scanned image.
+const ImageIDAnnotation = "scanner.zoltankerezsi.xyz/image-id"
+
+// ScanJobServiceAccount is the service account the scan Jobs upload their
+// reports with.
+const ScanJobServiceAccount = "scanner-job"
+
+// The keys of the Secret of a scan Job.
+const (
+	UploadTokenKey = "upload-token"
+	CACertKey      = "ca.crt"
+)
+
+// JobObjectOptions configures how the scan Jobs reach the API.
+type JobObjectOptions struct {
+	// APIServiceHostname is the host the reports are uploaded to.
+	APIServiceHostname string
+	APIPort            int
+	// CAFile is the CA bundle the certificate of the API is verified with.
+	// The reports are uploaded over HTTPS if set. The scan Jobs present no
+	// client certificate, as its key would be readable in every scanned
+	// namespace. The uploads are authenticated by the token of the service
+	// account and the upload token instead.
+	CAFile string
+}
+
 type JobObjectServiceInterface interface {
-	Create(imageID string, namespace string) (*batchv1.Job, error)
+	Create(ctx context.Context, imageID string, namespace string, triggers []ImageUsage) (*batchv1.Job, *corev1.Secret, error)
 }
 
+var tracer = otel.Tracer("github.com/kerezsiz42/scanner-operator2/internal/service")
+
 type JobObjectService struct {
-	t                  *template.Template
-	decoder            runtime.Serializer
-	apiServiceHostname string
+	t            *template.Template
+	decoder      runtime.Serializer
+	options      JobObjectOptions
+	uploadTokens UploadTokenServiceInterface
 }
 
-func NewJobObjectService() (*JobObjectService, error) {
+// NewJobObjectService creates the Jobs uploading their reports to the API
+// described by options.
+func NewJobObjectService(options JobObjectOptions, uploadTokens UploadTokenServiceInterface) (*JobObjectService, error) {
 	t, err := template.New("job.template.yaml").Parse(JobTemplateYAML)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse job.template.yaml: %w", err)
 	}
 
-	apiServiceHostname := os.Getenv("API_SERVICE_HOSTNAME")
-	if apiServiceHostname == "" {
-		return nil, errors.New("API_SERVICE_HOSTNAME environment variable not set")
+	if options.APIServiceHostname == "" {
+		return nil, errors.New("API service hostname not set")
+	}
+
+	if options.APIPort <= 0 {
+		return nil, errors.New("API port not set")
+	}
+
+	j := &JobObjectService{
+		t:            t,
+		decoder:      yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme),
+		options:      options,
+		uploadTokens: uploadTokens,
+	}
+
+	if _, err := j.tlsData(); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// tlsData reads the CA passed to the scan Jobs. It is read for every Job, so
+// that the Jobs get the rotated one.
+func (j *JobObjectService) tlsData() (map[string][]byte, error) {
+	data := map[string][]byte{}
+	if j.options.CAFile == "" {
+		return data, nil
+	}
+
+	value, err := os.ReadFile(j.options.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s of the scan jobs: %w", CACertKey, err)
+	}
+
+	data[CACertKey] = value
+	return data, nil
+}
+
+// Create builds a Job scanning the image. The containers which are running the
+// image are passed along with the report to the API, which is authorized by
+// an upload token bound to the image and the Job. The token is kept in the
+// returned Secret of the same name, which has to be created along with the
+// Job. The trace of ctx is passed to the Job, so that the upload of the
+// report continues it.
+func (j *JobObjectService) Create(ctx context.Context, imageID string, namespace string, triggers []ImageUsage) (*batchv1.Job, *corev1.Secret, error) {
+	ctx, span := tracer.Start(ctx, "JobObjectService.Create")
+	defer span.End()
+
+	job, secret, err := j.create(ctx, imageID, namespace, triggers)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, nil, err
 	}
 
-	return &JobObjectService{
-		t:                  t,
-		decoder:            yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme),
-		apiServiceHostname: apiServiceHostname,
-	}, nil
+	span.SetAttributes(attribute.String("k8s.job.name", job.Name), attribute.String("k8s.namespace.name", namespace))
+	return job, secret, nil
 }
 
-func (j *JobObjectService) Create(imageID string, namespace string) (*batchv1.Job, error) {
+func (j *JobObjectService) create(ctx context.Context, imageID string, namespace string, triggers []ImageUsage) (*batchv1.Job, *corev1.Secret, error) {
+	type triggeredBy struct {
+		Namespace string `json:"namespace"`
+		Pod       string `json:"pod"`
+		Container string `json:"container"`
+	}
+
+	triggeredByList := []triggeredBy{}
+	for _, trigger := range triggers {
+		triggeredByList = append(triggeredByList, triggeredBy{
+			Namespace: trigger.Namespace,
+			Pod:       trigger.Pod,
+			Container: trigger.Container,
+		})
+	}
+
+	triggeredByJSON, err := json.Marshal(triggeredByList)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal scan triggers: %w", err)
+	}
+
+	tlsData, err := j.tlsData()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scheme := "http"
+	if _, ok := tlsData[CACertKey]; ok {
+		scheme = "https"
+	}
+
+	scanName := fmt.Sprintf("scan-%s", utils.GenerateId())
+	uploadToken, err := j.uploadTokens.Issue(scanName, imageID)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	jobTemplateVars := struct {
 		ScanName           string
 		ImageID            string
 		Namespace          string
 		ApiServiceHostname string
+		APIScheme          string
+		APIPort            int
+		ServiceAccountName string
+		TriggeredBy        string
+		SecretName         string
+		UploadTokenKey     string
+		Traceparent        string
+		CreatedAt          int64
 	}{
-		ScanName:           fmt.Sprintf("scan-%s", utils.GenerateId()),
+		ScanName:           scanName,
 		ImageID:            imageID,
 		Namespace:          namespace,
-		ApiServiceHostname: j.apiServiceHostname,
+		ApiServiceHostname: j.options.APIServiceHostname,
+		APIScheme:          scheme,
+		APIPort:            j.options.APIPort,
+		ServiceAccountName: ScanJobServiceAccount,
+		TriggeredBy:        string(triggeredByJSON),
+		SecretName:         scanName,
+		UploadTokenKey:     UploadTokenKey,
+		Traceparent:        tracing.Traceparent(ctx),
+		CreatedAt:          time.Now().Unix(),
 	}
 
 	var buf bytes.Buffer
 	if err := j.t.Execute(&buf, jobTemplateVars); err != nil {
-		return nil, fmt.Errorf("failed to execute variable substitution in job.template.yaml: %w", err)
+		return nil, nil, fmt.Errorf("failed to execute variable substitution in job.template.yaml: %w", err)
 	}
 
 	job := &batchv1.Job{}
-	_, _, err := j.decoder.Decode(buf.Bytes(), nil, job)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode buffer: %w", err)
+	if _, _, err := j.decoder.Decode(buf.Bytes(), nil, job); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode buffer: %w", err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      scanName,
+			Namespace: namespace,
+		},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: map[string]string{UploadTokenKey: uploadToken},
+		Data:       tlsData,
 	}
 
-	return job, nil
+	return job, secret, nil
 }
@@ -1 +1,152 @@
 package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestJobObjectServiceCreate(t *testing.T) {
+	uploadTokens := newTestUploadTokenService(t)
+	j, err := NewJobObjectService(JobObjectOptions{APIServiceHostname: "scanner-api", APIPort: 8000}, uploadTokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, secret, err := j.Create(context.Background(), "alpine@sha256:1", "team-a", []ImageUsage{
+		{Namespace: "team-a", Pod: "web-1", Container: "nginx"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if job.Namespace != "team-a" || !strings.HasPrefix(job.Name, "scan-") {
+		t.Errorf("unexpected job metadata: %s/%s", job.Namespace, job.Name)
+	}
+
+	if job.Annotations[ImageIDAnnotation] != "alpine@sha256:1" {
+		t.Errorf("unexpected image ID annotation: %v", job.Annotations)
+	}
+
+	script := job.Spec.Template.Spec.Containers[0].Args[0]
+	if !strings.Contains(script, `"triggeredBy":[{"namespace":"team-a","pod":"web-1","container":"nginx"}]`) {
+		t.Errorf("scan triggers are missing from the upload script:\n%s", script)
+	}
+
+	if job.Spec.Template.Spec.ServiceAccountName != ScanJobServiceAccount ||
+		!strings.Contains(script, "Authorization: Bearer") {
+		t.Errorf("the upload is not authenticated with the service account of the job:\n%s", script)
+	}
+
+	if !strings.Contains(script, " http://scanner-api:8000/scan-results") || strings.Contains(script, "--cacert") {
+		t.Errorf("expected the report to be uploaded over HTTP without TLS:\n%s", script)
+	}
+
+	if !strings.Contains(script, "curl --fail-with-body ") || *job.Spec.BackoffLimit != 0 {
+		t.Errorf("expected a rejected upload to fail the job without retrying it:\n%s", script)
+	}
+
+	env := job.Spec.Template.Spec.Containers[0].Env
+	if len(env) != 2 || env[0].Name != "UPLOAD_TOKEN" || env[0].Value != "" || env[0].ValueFrom == nil ||
+		env[0].ValueFrom.SecretKeyRef == nil || env[0].ValueFrom.SecretKeyRef.Name != job.Name ||
+		env[0].ValueFrom.SecretKeyRef.Key != UploadTokenKey {
+		t.Errorf("expected the upload token to be read from the secret of the job, got %v", env)
+	}
+
+	if secret.Name != job.Name || secret.Namespace != job.Namespace ||
+		uploadTokens.Use(context.Background(), secret.StringData[UploadTokenKey], "alpine@sha256:1") != nil {
+		t.Errorf("expected an upload token of the image in the secret, got %s/%s", secret.Namespace, secret.Name)
+	}
+
+	if env[1].Name != "TRACEPARENT" || env[1].Value != "" {
+		t.Errorf("expected an empty traceparent without a trace, got %v", env[1])
+	}
+}
+
+func TestJobObjectServiceCreatePropagatesTrace(t *testing.T) {
+	j, err := NewJobObjectService(JobObjectOptions{APIServiceHostname: "scanner-api", APIPort: 8000}, newTestUploadTokenService(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "reconcile")
+
+	job, _, err := j.Create(ctx, "alpine@sha256:1", "team-a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	traceID := parent.SpanContext().TraceID().String()
+	env := job.Spec.Template.Spec.Containers[0].Env
+	if env[1].Name != "TRACEPARENT" || !strings.HasPrefix(env[1].Value, "00-"+traceID+"-") {
+		t.Errorf("expected a traceparent of trace %s, got %v", traceID, env[1])
+	}
+
+	script := job.Spec.Template.Spec.Containers[0].Args[0]
+	if !strings.Contains(script, `traceparent: $TRACEPARENT`) {
+		t.Errorf("the upload does not continue the trace:\n%s", script)
+	}
+}
+
+func TestJobObjectServiceCreateWithTLS(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ca.crt"), []byte("ca"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := NewJobObjectService(JobObjectOptions{
+		APIServiceHostname: "scanner-api",
+		APIPort:            8443,
+		CAFile:             filepath.Join(dir, "ca.crt"),
+	}, newTestUploadTokenService(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, secret, err := j.Create(context.Background(), "alpine@sha256:1", "team-a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script := job.Spec.Template.Spec.Containers[0].Args[0]
+	for _, expected := range []string{
+		"--cacert /etc/scanner/api/ca.crt",
+		" https://scanner-api:8443/scan-results",
+	} {
+		if !strings.Contains(script, expected) {
+			t.Errorf("expected %q in the upload script:\n%s", expected, script)
+		}
+	}
+
+	if strings.Contains(script, "--key") {
+		t.Errorf("expected no client certificate in the upload script:\n%s", script)
+	}
+
+	volumes := job.Spec.Template.Spec.Volumes
+	if volumes[len(volumes)-1].Secret == nil || volumes[len(volumes)-1].Secret.SecretName != secret.Name {
+		t.Errorf("expected the secret of the job to be mounted, got %v", volumes)
+	}
+
+	if len(secret.Data) != 1 || string(secret.Data[CACertKey]) != "ca" {
+		t.Errorf("expected only the CA in the secret, got %v", secret.Data)
+	}
+}
+
+func TestNewJobObjectServiceRejectsMissingCA(t *testing.T) {
+	_, err := NewJobObjectService(JobObjectOptions{
+		APIServiceHostname: "scanner-api",
+		APIPort:            8443,
+		CAFile:             filepath.Join(t.TempDir(), "ca.crt"),
+	}, newTestUploadTokenService(t))
+	if err == nil {
+		t.Error("expected a missing CA of the API to be rejected")
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	cyclonedx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"gorm.io/gorm"
+)
+
+var InvalidCursor = errors.New("invalid cursor")
+
+type ScanResultSort string
+
+const (
+	SortByImageID   ScanResultSort = "image_id"
+	SortByCreatedAt ScanResultSort = "created_at"
+	SortByUpdatedAt ScanResultSort = "updated_at"
+)
+
+// ListScanResultsOptions filters, sorts and paginates ScanResults. Zero values
+// disable the corresponding filter, and a Limit of 0 lists every ScanResult.
+type ListScanResultsOptions struct {
+	Limit  int
+	Cursor string
+	// Namespace matches the images which were running in the namespace when
+	// they were scanned.
+	Namespace string
+	// VisibleNamespaces restricts the ScanResults to the images recorded in
+	// any of the namespaces. Unlike the other filters, only nil disables it.
+	VisibleNamespaces []string
+	// MinSeverity matches the images having a vulnerability of at least this
+	// severity according to their VulnerabilitySummary.
+	MinSeverity string
+	HasFix      *bool
+	// Repository matches the images pulled by digest from the repository.
+	Repository   string
+	ScannedSince *time.Time
+	Sort         ScanResultSort
+	Descending   bool
+	// WithoutReports leaves the reports out, which is considerably cheaper.
+	WithoutReports bool
+}
+
+type ScanResultPage struct {
+	ScanResults []*database.ScanResult
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
+
+// cursor is the position of the last ScanResult of a page. Time is the value
+// of the sort column unless sorting by image ID.
+type cursor struct {
+	Time    *time.Time `json:"t,omitempty"`
+	ImageID string     `json:"i"`
+}
+
+func encodeCursor(scanResult *database.ScanResult, sort ScanResultSort) string {
+	c := cursor{ImageID: scanResult.ImageID}
+	switch sort {
+	case SortByCreatedAt:
+		c.Time = &scanResult.CreatedAt
+	case SortByUpdatedAt:
+		c.Time = &scanResult.UpdatedAt
+	}
+
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string, sort ScanResultSort) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, InvalidCursor
+	}
+
+	c := cursor{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, InvalidCursor
+	}
+
+	if (c.Time == nil) != (sort == SortByImageID) {
+		return nil, InvalidCursor
+	}
+
+	return &c, nil
+}
+
+// summarySeverities maps the severities to the columns of the
+// VulnerabilitySummary counting them.
+var summarySeverities = []struct {
+	severity cyclonedx.Severity
+	column   string
+}{
+	{cyclonedx.SeverityCritical, "critical_count"},
+	{cyclonedx.SeverityHigh, "high_count"},
+	{cyclonedx.SeverityMedium, "medium_count"},
+	{cyclonedx.SeverityLow, "low_count"},
+	{cyclonedx.SeverityInfo, "info_count"},
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern using ! as the escape
+// character, which needs no escaping in any of the supported dialects.
+func escapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
+
+func (s *ScanService) ListScanResults(ctx context.Context, options ListScanResultsOptions) (*ScanResultPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	if options.Sort == "" {
+		options.Sort = SortByImageID
+	}
+
+	query, err := s.scanResultsQuery(options)
+	if err != nil {
+		return nil, err
+	}
+
+	scanResults := []*database.ScanResult{}
+	if err := query.WithContext(ctx).Preload("Triggers").Find(&scanResults).Error; err != nil {
+		return nil, fmt.Errorf("error while listing ScanResults: %w", err)
+	}
+
+	page := &ScanResultPage{ScanResults: scanResults}
+	if options.Limit > 0 && len(scanResults) > options.Limit {
+		page.ScanResults = scanResults[:options.Limit]
+		page.NextCursor = encodeCursor(page.ScanResults[options.Limit-1], options.Sort)
+	}
+
+	if options.WithoutReports {
+		return page, nil
+	}
+
+	hashes := []string{}
+	for _, scanResult := range page.ScanResults {
+		hashes = append(hashes, scanResult.ReportHash)
+	}
+
+	reports, err := s.loadReports(ctx, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("error while listing ScanResults: %w", err)
+	}
+
+	for _, scanResult := range page.ScanResults {
+		scanResult.Report = reports[scanResult.ReportHash]
+	}
+
+	return page, nil
+}
+
+func (s *ScanService) scanResultsQuery(options ListScanResultsOptions) (*gorm.DB, error) {
+	switch options.Sort {
+	case SortByImageID, SortByCreatedAt, SortByUpdatedAt:
+	default:
+		return nil, fmt.Errorf("unsupported sort: %s", options.Sort)
+	}
+
+	query := s.db.Model(&database.ScanResult{})
+	if options.Namespace != "" {
+		triggers := s.db.Model(&database.ScanTrigger{}).Select("1").
+			Where("scan_triggers.image_id = scan_results.image_id AND scan_triggers.namespace = ?", options.Namespace)
+		query = query.Where("EXISTS (?)", triggers)
+	}
+
+	if options.VisibleNamespaces != nil {
+		imageNamespaces := s.db.Model(&database.ImageNamespace{}).Select("1").
+			Where("image_namespaces.image_id = scan_results.image_id AND image_namespaces.namespace IN ?", options.VisibleNamespaces)
+		query = query.Where("EXISTS (?)", imageNamespaces)
+	}
+
+	if options.MinSeverity != "" {
+		conditions := []string{}
+		for _, severity := range summarySeverities {
+			if SeverityRank(string(severity.severity)) >= SeverityRank(options.MinSeverity) {
+				conditions = append(conditions, severity.column+" > 0")
+			}
+		}
+
+		if len(conditions) == 0 {
+			return nil, fmt.Errorf("unsupported severity: %s", options.MinSeverity)
+		}
+
+		query = query.Where("(" + strings.Join(conditions, " OR ") + ")")
+	}
+
+	if options.HasFix != nil {
+		if *options.HasFix {
+			query = query.Where("fixable_count > 0")
+		} else {
+			query = query.Where("fixable_count = 0")
+		}
+	}
+
+	if options.Repository != "" {
+		query = query.Where("image_id LIKE ? ESCAPE '!'", escapeLike(options.Repository)+"@%")
+	}
+
+	if options.ScannedSince != nil {
+		query = query.Where("updated_at >= ?", *options.ScannedSince)
+	}
+
+	column := string(options.Sort)
+	operator, direction := ">", "ASC"
+	if options.Descending {
+		operator, direction = "<", "DESC"
+	}
+
+	if options.Cursor != "" {
+		c, err := decodeCursor(options.Cursor, options.Sort)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.Time == nil {
+			query = query.Where("image_id "+operator+" ?", c.ImageID)
+		} else {
+			query = query.Where(
+				"("+column+" "+operator+" ? OR ("+column+" = ? AND image_id "+operator+" ?))",
+				*c.Time, *c.Time, c.ImageID,
+			)
+		}
+	}
+
+	if options.Sort != SortByImageID {
+		query = query.Order(column + " " + direction)
+	}
+
+	query = query.Order("image_id " + direction)
+	if options.Limit > 0 {
+		query = query.Limit(options.Limit + 1)
+	}
+
+	return query, nil
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+)
+
+func TestListScanResults(t *testing.T) {
+	s := newTestScanService(t)
+	report, _ := readTestBOM(t)
+	lowOnly := strings.NewReplacer(`"critical"`, `"low"`, `"high"`, `"low"`, `"medium"`, `"low"`).Replace(report)
+	unfixable := strings.Replace(report, `"recommendation": "Upgrade libssl3 to 3.0.8-r1",`, "", 1)
+	uploads := []struct {
+		imageId   string
+		report    string
+		namespace string
+	}{
+		{"docker.io/library/alpine@sha256:1", report, "default"},
+		{"docker.io/library/alpine@sha256:2", lowOnly, "default"},
+		{"docker.io/library/alpine_x@sha256:3", unfixable, "kube-system"},
+		{"docker.io/library/debian@sha256:4", report, "kube-system"},
+		{"docker.io/library/debian@sha256:5", report, "default"},
+	}
+
+	for _, upload := range uploads {
+		triggers := []database.ScanTrigger{{Namespace: upload.namespace, Pod: "pod", Container: "container"}}
+		if _, _, err := s.UpsertScanResult(context.Background(), upload.imageId, upload.report, database.ScanMetadata{}, triggers); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func(options ListScanResultsOptions) []string {
+		t.Helper()
+		imageIds := []string{}
+		for {
+			page, err := s.ListScanResults(context.Background(), options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, scanResult := range page.ScanResults {
+				imageIds = append(imageIds, scanResult.ImageID)
+			}
+
+			if page.NextCursor == "" {
+				return imageIds
+			}
+
+			options.Cursor = page.NextCursor
+		}
+	}
+
+	all := []string{}
+	for _, upload := range uploads {
+		all = append(all, upload.imageId)
+	}
+
+	if imageIds := list(ListScanResultsOptions{Limit: 2}); !slices.Equal(imageIds, all) {
+		t.Errorf("unexpected pages: %v", imageIds)
+	}
+
+	reversed := slices.Clone(all)
+	slices.Reverse(reversed)
+	if imageIds := list(ListScanResultsOptions{Limit: 2, Sort: SortByUpdatedAt, Descending: true}); !slices.Equal(imageIds, reversed) {
+		t.Errorf("unexpected pages sorted by updatedAt: %v", imageIds)
+	}
+
+	hasFix := false
+	since := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name    string
+		options ListScanResultsOptions
+		want    []string
+	}{
+		{"namespace", ListScanResultsOptions{Namespace: "kube-system"}, []string{all[2], all[3]}},
+		{"minSeverity", ListScanResultsOptions{MinSeverity: "high"}, []string{all[0], all[2], all[3], all[4]}},
+		{"hasFix", ListScanResultsOptions{HasFix: &hasFix}, []string{all[2]}},
+		{"repository", ListScanResultsOptions{Repository: "docker.io/library/alpine"}, []string{all[0], all[1]}},
+		{"scannedSince", ListScanResultsOptions{ScannedSince: &since, Namespace: "default", Limit: 1}, []string{all[0], all[1], all[4]}},
+	}
+
+	for _, tt := range tests {
+		if imageIds := list(tt.options); !slices.Equal(imageIds, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, imageIds, tt.want)
+		}
+	}
+
+	page, err := s.ListScanResults(context.Background(), ListScanResultsOptions{Limit: 1, WithoutReports: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if page.ScanResults[0].Report != "" || len(page.ScanResults[0].Triggers) != 1 {
+		t.Errorf("unexpected summary: %+v", page.ScanResults[0])
+	}
+
+	_, err = s.ListScanResults(context.Background(), ListScanResultsOptions{Cursor: page.NextCursor, Sort: SortByCreatedAt})
+	if !errors.Is(err, InvalidCursor) {
+		t.Errorf("expected a cursor of another sort to be rejected, got %v", err)
+	}
+}
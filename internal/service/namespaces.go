@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecordImageNamespaces records that the images are running in the namespace.
+// The images recorded already are left as they are.
+func (s *ScanService) RecordImageNamespaces(ctx context.Context, namespace string, imageIds []string) error {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	if err := recordImageNamespaces(s.db.WithContext(ctx), namespace, imageIds); err != nil {
+		return fmt.Errorf("error while recording ImageNamespaces: %w", err)
+	}
+
+	return nil
+}
+
+func recordImageNamespaces(tx *gorm.DB, namespace string, imageIds []string) error {
+	if len(imageIds) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	imageNamespaces := []database.ImageNamespace{}
+	for _, imageId := range imageIds {
+		imageNamespaces = append(imageNamespaces, database.ImageNamespace{
+			ImageID:   imageId,
+			Namespace: namespace,
+			CreatedAt: now,
+		})
+	}
+
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(imageNamespaces, indexBatchSize).Error
+}
+
+// ImageNamespaces returns the namespaces recorded for each of the images.
+func (s *ScanService) ImageNamespaces(ctx context.Context, imageIds []string) (map[string][]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	imageNamespaces := []database.ImageNamespace{}
+	res := s.db.WithContext(ctx).Where("image_id IN ?", imageIds).Order("namespace").Find(&imageNamespaces)
+	if res.Error != nil {
+		return nil, fmt.Errorf("error while listing ImageNamespaces: %w", res.Error)
+	}
+
+	namespaces := map[string][]string{}
+	for _, imageNamespace := range imageNamespaces {
+		namespaces[imageNamespace.ImageID] = append(namespaces[imageNamespace.ImageID], imageNamespace.Namespace)
+	}
+
+	return namespaces, nil
+}
+
+// ListNamespaces returns every namespace recorded for any image.
+func (s *ScanService) ListNamespaces(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	namespaces := []string{}
+	res := s.db.WithContext(ctx).Model(&database.ImageNamespace{}).Distinct("namespace").Order("namespace").Pluck("namespace", &namespaces)
+	if res.Error != nil {
+		return nil, fmt.Errorf("error while listing namespaces: %w", res.Error)
+	}
+
+	return namespaces, nil
+}
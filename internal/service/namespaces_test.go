@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+)
+
+func TestImageNamespaces(t *testing.T) {
+	s := newTestScanService(t)
+	report, _ := readTestBOM(t)
+	triggers := []database.ScanTrigger{{Namespace: "team-a", Pod: "pod", Container: "container"}}
+	if _, _, err := s.UpsertScanResult(context.Background(), "alpine@sha256:1", report, database.ScanMetadata{}, triggers); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.UpsertScanResult(context.Background(), "debian@sha256:2", report, database.ScanMetadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The triggers of a report do not record the namespaces of its image.
+	if namespaces, err := s.ImageNamespaces(context.Background(), []string{"alpine@sha256:1"}); err != nil ||
+		len(namespaces["alpine@sha256:1"]) != 0 {
+		t.Errorf("expected no namespaces recorded from the triggers, got %v (%v)", namespaces, err)
+	}
+
+	if err := s.RecordImageNamespaces(context.Background(), "team-a", []string{"alpine@sha256:1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Recording again is a no-op.
+	for i := 0; i < 2; i++ {
+		if err := s.RecordImageNamespaces(context.Background(), "team-b", []string{"alpine@sha256:1", "debian@sha256:2"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	namespaces, err := s.ImageNamespaces(context.Background(), []string{"alpine@sha256:1", "debian@sha256:2", "nginx@sha256:3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(namespaces["alpine@sha256:1"], []string{"team-a", "team-b"}) ||
+		!slices.Equal(namespaces["debian@sha256:2"], []string{"team-b"}) || len(namespaces["nginx@sha256:3"]) != 0 {
+		t.Errorf("unexpected namespaces: %v", namespaces)
+	}
+
+	if all, err := s.ListNamespaces(context.Background()); err != nil || !slices.Equal(all, []string{"team-a", "team-b"}) {
+		t.Errorf("unexpected namespaces: %v (%v)", all, err)
+	}
+
+	for _, c := range []struct {
+		visible  []string
+		expected []string
+	}{
+		{nil, []string{"alpine@sha256:1", "debian@sha256:2"}},
+		{[]string{"team-a"}, []string{"alpine@sha256:1"}},
+		{[]string{}, []string{}},
+	} {
+		page, err := s.ListScanResults(context.Background(), ListScanResultsOptions{VisibleNamespaces: c.visible, WithoutReports: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		imageIds := []string{}
+		for _, scanResult := range page.ScanResults {
+			imageIds = append(imageIds, scanResult.ImageID)
+		}
+
+		if !slices.Equal(imageIds, c.expected) {
+			t.Errorf("visible namespaces %v: expected %v, got %v", c.visible, c.expected, imageIds)
+		}
+	}
+}
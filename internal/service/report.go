@@ -0,0 +1,146 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReportStorageStats compares the size of the reports referenced by
+// ScanResults and Scans with the size actually stored.
+type ReportStorageStats struct {
+	ReferencedBytes int64
+	StoredBytes     int64
+}
+
+func (r ReportStorageStats) SavedBytes() int64 {
+	return r.ReferencedBytes - r.StoredBytes
+}
+
+func compressReport(report string) (*database.Report, []byte, error) {
+	sum := sha256.Sum256([]byte(report))
+	buf := bytes.Buffer{}
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(report)); err != nil {
+		return nil, nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	return &database.Report{
+		Hash:           hex.EncodeToString(sum[:]),
+		Size:           int64(len(report)),
+		CompressedSize: int64(buf.Len()),
+		CreatedAt:      time.Now(),
+	}, buf.Bytes(), nil
+}
+
+func decompressReport(data []byte) (string, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	defer reader.Close()
+	report, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+
+	return string(report), nil
+}
+
+// storeReport puts the compressed report into the ReportStore and returns its
+// metadata, which has to be created in the same transaction as the rows
+// referencing it. Reports stored by transactions rolled back later are not
+// referenced by anything, but do no harm either.
+func (s *ScanService) storeReport(ctx context.Context, report string) (*database.Report, error) {
+	metadata, data, err := compressReport(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress report: %w", err)
+	}
+
+	if err := s.reports.Store(ctx, metadata.Hash, data); err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
+
+func createReportMetadata(tx *gorm.DB, metadata *database.Report) error {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(metadata).Error
+}
+
+// loadReports returns the decompressed reports with the given hashes.
+func (s *ScanService) loadReports(ctx context.Context, hashes []string) (map[string]string, error) {
+	compressed, err := s.reports.Load(ctx, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := map[string]string{}
+	for hash, data := range compressed {
+		report, err := decompressReport(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress report %s: %w", hash, err)
+		}
+
+		reports[hash] = report
+	}
+
+	return reports, nil
+}
+
+func (s *ScanService) loadReport(ctx context.Context, hash string) (string, error) {
+	reports, err := s.loadReports(ctx, []string{hash})
+	if err != nil {
+		return "", err
+	}
+
+	report, ok := reports[hash]
+	if !ok {
+		return "", fmt.Errorf("report %s not found", hash)
+	}
+
+	return report, nil
+}
+
+// ReportStorageStats returns how much storage is saved by compressing and
+// deduplicating the reports.
+func (s *ScanService) ReportStorageStats(ctx context.Context) (*ReportStorageStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	db := s.db.WithContext(ctx)
+	stats := ReportStorageStats{}
+	for _, table := range []string{"scan_results", "scans"} {
+		var referenced int64
+		res := db.Table(table).
+			Joins("JOIN reports ON reports.hash = " + table + ".report_hash").
+			Select("COALESCE(SUM(reports.size), 0)").
+			Scan(&referenced)
+		if res.Error != nil {
+			return nil, fmt.Errorf("error while getting ReportStorageStats: %w", res.Error)
+		}
+
+		stats.ReferencedBytes += referenced
+	}
+
+	res := db.Model(&database.Report{}).Select("COALESCE(SUM(compressed_size), 0)").Scan(&stats.StoredBytes)
+	if res.Error != nil {
+		return nil, fmt.Errorf("error while getting ReportStorageStats: %w", res.Error)
+	}
+
+	return &stats, nil
+}
@@ -1,81 +1,295 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	cyclonedx "github.com/CycloneDX/cyclonedx-go"
 	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"github.com/kerezsiz42/scanner-operator2/internal/storage"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 var InvalidCycloneDXBOM = errors.New("invalid CycloneDX BOM")
 
+const indexBatchSize = 500
+
 type ScanServiceInterface interface {
-	GetScanResult(imageId string) (*database.ScanResult, error)
-	ListScanResults() ([]*database.ScanResult, error)
-	DeleteScanResult(imageId string) error
-	UpsertScanResult(imageId string, report string) (*database.ScanResult, error)
+	GetScanResult(ctx context.Context, imageId string) (*database.ScanResult, error)
+	ListScanResults(ctx context.Context, options ListScanResultsOptions) (*ScanResultPage, error)
+	ListScannedImages(ctx context.Context) ([]ScannedImage, error)
+	ScannedImageIDs(ctx context.Context) (map[string]bool, error)
+	RequestRescans(ctx context.Context, scannedBefore time.Time) (int64, error)
+	DeleteScanResult(ctx context.Context, imageId string) error
+	UpsertScanResult(
+		ctx context.Context,
+		imageId string,
+		report string,
+		metadata database.ScanMetadata,
+		triggers []database.ScanTrigger,
+	) (*database.ScanResult, bool, error)
+	FindVulnerabilities(ctx context.Context, vulnerabilityId string) ([]*database.Vulnerability, error)
+	FindComponents(ctx context.Context, purl string) ([]*database.Component, error)
+	ListScans(ctx context.Context, imageId string) ([]*database.Scan, error)
+	DiffScans(ctx context.Context, imageId string, fromId *uint, toId *uint) (*ScanDiff, error)
+	RecordImageNamespaces(ctx context.Context, namespace string, imageIds []string) error
+	ImageNamespaces(ctx context.Context, imageIds []string) (map[string][]string, error)
+	ListNamespaces(ctx context.Context) ([]string, error)
 }
 
+// queryTimeout and writeTimeout bound the operations of the ScanService, so
+// that a slow database cannot hold the callers whose context has no deadline.
+// The writes store the report as well, so they are given longer.
+const (
+	queryTimeout = 15 * time.Second
+	writeTimeout = time.Minute
+)
+
 type ScanService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	reports       storage.ReportStore
+	scannedImages scannedImageCache
 }
 
-func NewScanService(db *gorm.DB) *ScanService {
+func NewScanService(db *gorm.DB, reports storage.ReportStore) *ScanService {
 	return &ScanService{
-		db: db,
+		db:      db,
+		reports: reports,
 	}
 }
 
-func (s *ScanService) GetScanResult(imageId string) (*database.ScanResult, error) {
+func (s *ScanService) GetScanResult(ctx context.Context, imageId string) (*database.ScanResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
 	scanResult := database.ScanResult{}
-	res := s.db.First(&scanResult, "image_id = ?", imageId)
+	res := s.db.WithContext(ctx).Preload("Triggers").First(&scanResult, "image_id = ?", imageId)
 	if res.Error != nil {
 		return nil, fmt.Errorf("error while getting ScanResult: %w", res.Error)
 	}
 
+	report, err := s.loadReport(ctx, scanResult.ReportHash)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting ScanResult: %w", err)
+	}
+
+	scanResult.Report = report
 	return &scanResult, nil
 }
 
-func (s *ScanService) ListScanResults() ([]*database.ScanResult, error) {
-	scanResults := []*database.ScanResult{}
-	res := s.db.Find(&scanResults)
-	if res.Error != nil {
-		return nil, fmt.Errorf("error while listing ScanResults: %w", res.Error)
-	}
+func (s *ScanService) DeleteScanResult(ctx context.Context, imageId string) error {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
 
-	return scanResults, nil
-}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("image_id = ?", imageId).Delete(&database.ScanResult{}).Error; err != nil {
+			return err
+		}
 
-func (s *ScanService) DeleteScanResult(imageId string) error {
-	res := s.db.Where("image_id = ?", imageId).Delete(&database.ScanResult{})
-	if res.Error != nil {
-		return fmt.Errorf("error while deleting ScanResult: %w", res.Error)
+		if err := tx.Where("image_id = ?", imageId).Delete(&database.ScanTrigger{}).Error; err != nil {
+			return err
+		}
+
+		return deleteIndex(tx, imageId)
+	})
+	if err != nil {
+		return fmt.Errorf("error while deleting ScanResult: %w", err)
 	}
 
+	s.scannedImages.invalidate()
+
 	return nil
 }
 
-func (s *ScanService) UpsertScanResult(imageId string, report string) (*database.ScanResult, error) {
+// UpsertScanResult stores the report as the latest ScanResult of the image and
+// appends it to the scan history. The report itself is stored compressed and
+// shared with every other ScanResult and Scan having the same report. The
+// scanner name and version are taken from the BOM when they are not part of
+// the metadata. The triggers are sent by the scan Job, so they do not record
+// the namespaces of the image, which decide who can see it. The returned bool
+// reports whether the ScanResult was created.
+func (s *ScanService) UpsertScanResult(
+	ctx context.Context,
+	imageId string,
+	report string,
+	metadata database.ScanMetadata,
+	triggers []database.ScanTrigger,
+) (*database.ScanResult, bool, error) {
 	bom := cyclonedx.BOM{}
 	reader := strings.NewReader(report)
 	decoder := cyclonedx.NewBOMDecoder(reader, cyclonedx.BOMFileFormatJSON)
 	if err := decoder.Decode(&bom); err != nil {
-		return nil, fmt.Errorf("%w: %w", InvalidCycloneDXBOM, err)
+		return nil, false, fmt.Errorf("%w: %w", InvalidCycloneDXBOM, err)
+	}
+
+	if metadata.ScannerName == "" && metadata.ScannerVersion == "" {
+		metadata.ScannerName, metadata.ScannerVersion = scannerFromBOM(&bom)
 	}
 
 	scanResult := database.ScanResult{
-		ImageID: imageId,
-		Report:  report,
+		ImageID:              imageId,
+		Report:               report,
+		ScanMetadata:         metadata,
+		VulnerabilitySummary: summarizeBOM(&bom),
+	}
+
+	scan := database.Scan{
+		ImageID:      imageId,
+		Report:       report,
+		ScanMetadata: metadata,
+	}
+
+	for i := range triggers {
+		triggers[i].ImageID = imageId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	storedReport, err := s.storeReport(ctx, report)
+	if err != nil {
+		return nil, false, fmt.Errorf("error while inserting ScanResult: %w", err)
+	}
+
+	scanResult.ReportHash = storedReport.Hash
+	scan.ReportHash = storedReport.Hash
+	created := false
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := createReportMetadata(tx, storedReport); err != nil {
+			return err
+		}
+
+		var existing int64
+		if err := tx.Model(&database.ScanResult{}).Where("image_id = ?", imageId).Count(&existing).Error; err != nil {
+			return err
+		}
+
+		created = existing == 0
+
+		err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{UpdateAll: true}).Create(&scanResult).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Create(&scan).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("image_id = ?", imageId).Delete(&database.ScanTrigger{}).Error; err != nil {
+			return err
+		}
+
+		if len(triggers) > 0 {
+			if err := tx.Create(triggers).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := replaceIndex(tx, imageId, &bom); err != nil {
+			return err
+		}
+
+		return tx.Preload("Triggers").First(&scanResult, "image_id = ?", imageId).Error
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("error while inserting ScanResult: %w", err)
 	}
 
-	res := s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&scanResult)
+	s.scannedImages.invalidate()
+
+	return &scanResult, created, nil
+}
+
+// FindVulnerabilities returns the findings matching the given advisory
+// identifier or alias across all images.
+func (s *ScanService) FindVulnerabilities(ctx context.Context, vulnerabilityId string) ([]*database.Vulnerability, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	vulnerabilities := []*database.Vulnerability{}
+	res := s.db.WithContext(ctx).Where("vulnerability_id = ?", vulnerabilityId).Order("image_id").Find(&vulnerabilities)
 	if res.Error != nil {
-		return nil, fmt.Errorf("error while inserting ScanResult: %w", res.Error)
+		return nil, fmt.Errorf("error while finding Vulnerabilities: %w", res.Error)
 	}
 
-	return &scanResult, nil
+	return vulnerabilities, nil
+}
+
+// FindComponents returns the components matching the given package URL across
+// all images. A package URL without a version matches every version.
+func (s *ScanService) FindComponents(ctx context.Context, purl string) ([]*database.Component, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	db := s.db.WithContext(ctx)
+	purl, base := NormalizePurl(purl)
+	query := db.Where("purl = ?", purl)
+	if purl == base {
+		query = db.Where("purl_base = ?", base)
+	}
+
+	components := []*database.Component{}
+	res := query.Order("image_id").Find(&components)
+	if res.Error != nil {
+		return nil, fmt.Errorf("error while finding Components: %w", res.Error)
+	}
+
+	return components, nil
+}
+
+// replaceIndex replaces the search index of an image with the components and
+// vulnerabilities of the BOM.
+func replaceIndex(tx *gorm.DB, imageId string, bom *cyclonedx.BOM) error {
+	if err := deleteIndex(tx, imageId); err != nil {
+		return err
+	}
+
+	components, vulnerabilities := indexBOM(imageId, bom)
+	if len(components) > 0 {
+		if err := tx.CreateInBatches(components, indexBatchSize).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(vulnerabilities) > 0 {
+		if err := tx.CreateInBatches(vulnerabilities, indexBatchSize).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// summaryColumns are the columns of database.VulnerabilitySummary.
+var summaryColumns = []string{
+	"critical_count",
+	"high_count",
+	"medium_count",
+	"low_count",
+	"info_count",
+	"unknown_count",
+	"fixable_count",
+	"max_cvss_score",
+	"component_count",
+}
+
+// updateSummary replaces the VulnerabilitySummary of a ScanResult without
+// touching its update time.
+func updateSummary(tx *gorm.DB, imageId string, bom *cyclonedx.BOM) error {
+	return tx.Model(&database.ScanResult{}).
+		Where("image_id = ?", imageId).
+		Select(summaryColumns).
+		UpdateColumns(database.ScanResult{VulnerabilitySummary: summarizeBOM(bom)}).Error
+}
+
+func deleteIndex(tx *gorm.DB, imageId string) error {
+	if err := tx.Where("image_id = ?", imageId).Delete(&database.Component{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Where("image_id = ?", imageId).Delete(&database.Vulnerability{}).Error
 }
@@ -1 +1,387 @@
 package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"github.com/kerezsiz42/scanner-operator2/internal/storage"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestScanService(t testing.TB) *ScanService {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every connection to :memory: opens a new database.
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB.SetMaxOpenConns(1)
+
+	reportStore := storage.NewDatabaseReportStore(db)
+	if _, err := database.Migrate(context.Background(), db, Backfills(reportStore), false); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewScanService(db, reportStore)
+}
+
+func TestDiffScans(t *testing.T) {
+	s := newTestScanService(t)
+	report, _ := readTestBOM(t)
+	if _, _, err := s.UpsertScanResult(context.Background(), "alpine", report, database.ScanMetadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	next := strings.Replace(report, `"severity": "critical"`, `"severity": "low"`, 1)
+	next = strings.Replace(next, `"id": "CVE-2023-0464"`, `"id": "CVE-2023-0465"`, 1)
+	if _, _, err := s.UpsertScanResult(context.Background(), "alpine", next, database.ScanMetadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := s.DiffScans(context.Background(), "alpine", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff.From == nil || diff.From.ID != 1 || diff.To.ID != 2 {
+		t.Fatalf("unexpected scans compared: %v, %v", diff.From, diff.To)
+	}
+
+	if len(diff.Introduced) != 1 || diff.Introduced[0].VulnerabilityID != "CVE-2023-0465" {
+		t.Errorf("unexpected introduced findings: %+v", diff.Introduced)
+	}
+
+	if len(diff.Fixed) != 1 || diff.Fixed[0].VulnerabilityID != "CVE-2023-0464" {
+		t.Errorf("unexpected fixed findings: %+v", diff.Fixed)
+	}
+
+	if len(diff.SeverityChanged) != 1 || diff.SeverityChanged[0].PreviousSeverity != "critical" {
+		t.Errorf("unexpected severity changes: %+v", diff.SeverityChanged)
+	}
+
+	first := uint(1)
+	diff, err = s.DiffScans(context.Background(), "alpine", nil, &first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff.From != nil || len(diff.Introduced) != 2 {
+		t.Errorf("expected every finding of the first scan to be introduced: %+v", diff)
+	}
+}
+
+func TestDiffScansUpgradedPackage(t *testing.T) {
+	s := newTestScanService(t)
+	report, _ := readTestBOM(t)
+	if _, _, err := s.UpsertScanResult(context.Background(), "alpine", report, database.ScanMetadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.UpsertScanResult(context.Background(), "alpine", upgradeLibssl3(report), database.ScanMetadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := s.DiffScans(context.Background(), "alpine", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diff.Introduced) != 0 || len(diff.Fixed) != 0 || len(diff.SeverityChanged) != 0 {
+		t.Errorf("expected no changes after upgrading a vulnerable package: %+v", diff)
+	}
+}
+
+func TestUpsertScanResultCreated(t *testing.T) {
+	s := newTestScanService(t)
+	report, _ := readTestBOM(t)
+	for i, expected := range []bool{true, false} {
+		_, created, err := s.UpsertScanResult(context.Background(), "alpine", report, database.ScanMetadata{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if created != expected {
+			t.Errorf("upload %d: expected created to be %v", i, expected)
+		}
+	}
+}
+
+func TestScannedImageIDs(t *testing.T) {
+	s := newTestScanService(t)
+	report, _ := readTestBOM(t)
+	if _, _, err := s.UpsertScanResult(context.Background(), "alpine", report, database.ScanMetadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	imageIDs, err := s.ScannedImageIDs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(imageIDs) != 1 || !imageIDs["alpine"] {
+		t.Fatalf("unexpected scanned images: %v", imageIDs)
+	}
+
+	if _, _, err := s.UpsertScanResult(context.Background(), "debian", report, database.ScanMetadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteScanResult(context.Background(), "alpine"); err != nil {
+		t.Fatal(err)
+	}
+
+	imageIDs, err = s.ScannedImageIDs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(imageIDs) != 1 || !imageIDs["debian"] {
+		t.Errorf("expected the cache to be invalidated, got %v", imageIDs)
+	}
+}
+
+func TestRequestRescans(t *testing.T) {
+	s := newTestScanService(t)
+	report, _ := readTestBOM(t)
+	if _, _, err := s.UpsertScanResult(context.Background(), "alpine", report, database.ScanMetadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if requested, err := s.RequestRescans(context.Background(), time.Now().Add(-time.Hour)); err != nil || requested != 0 {
+		t.Fatalf("expected the recent scan to be kept, requested %d (%v)", requested, err)
+	}
+
+	if requested, err := s.RequestRescans(context.Background(), time.Now().Add(time.Hour)); err != nil || requested != 1 {
+		t.Fatalf("expected a rescan to be requested, requested %d (%v)", requested, err)
+	}
+
+	if imageIDs, err := s.ScannedImageIDs(context.Background()); err != nil || imageIDs["alpine"] {
+		t.Fatalf("expected the image to be rescanned, got %v (%v)", imageIDs, err)
+	}
+
+	if _, _, err := s.UpsertScanResult(context.Background(), "alpine", report, database.ScanMetadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if imageIDs, err := s.ScannedImageIDs(context.Background()); err != nil || !imageIDs["alpine"] {
+		t.Fatalf("expected the upload to clear the rescan request, got %v (%v)", imageIDs, err)
+	}
+}
+
+func TestCancelledContext(t *testing.T) {
+	s := newTestScanService(t)
+	report, _ := readTestBOM(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, err := s.UpsertScanResult(ctx, "alpine", report, database.ScanMetadata{}, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the upsert to be cancelled, got %v", err)
+	}
+
+	if _, err := s.ListScanResults(ctx, ListScanResultsOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the list to be cancelled, got %v", err)
+	}
+
+	if _, err := s.GetScanResult(context.Background(), "alpine"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected nothing to be stored, got %v", err)
+	}
+}
+
+func TestReportDeduplication(t *testing.T) {
+	s := newTestScanService(t)
+	report, _ := readTestBOM(t)
+	for _, imageId := range []string{"alpine", "alpine", "docker.io/library/alpine"} {
+		if _, _, err := s.UpsertScanResult(context.Background(), imageId, report, database.ScanMetadata{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var count int64
+	if err := s.db.Model(&database.Report{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Errorf("expected a single stored report, got %d", count)
+	}
+
+	scanResult, err := s.GetScanResult(context.Background(), "docker.io/library/alpine")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scanResult.Report != report {
+		t.Error("expected the stored report to be returned unchanged")
+	}
+
+	stats, err := s.ReportStorageStats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two ScanResults and three Scans reference the report.
+	if stats.ReferencedBytes != 5*int64(len(report)) || stats.SavedBytes() <= 4*int64(len(report)) {
+		t.Errorf("unexpected storage stats: %+v", stats)
+	}
+}
+
+// seedScanResults inserts n ScanResults directly, skipping the indexing done
+// by UpsertScanResult.
+func seedScanResults(b *testing.B, s *ScanService, n int) {
+	b.Helper()
+	report, _ := readTestBOM(b)
+	storedReport, err := s.storeReport(context.Background(), report)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	if err := createReportMetadata(s.db, storedReport); err != nil {
+		b.Fatal(err)
+	}
+
+	scanResults := make([]database.ScanResult, n)
+	for i := range scanResults {
+		scanResults[i] = database.ScanResult{ImageID: fmt.Sprintf("sha256:%064d", i), ReportHash: storedReport.Hash}
+	}
+
+	if err := s.db.CreateInBatches(scanResults, indexBatchSize).Error; err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkListScanResults(b *testing.B) {
+	s := newTestScanService(b)
+	seedScanResults(b, s, 20000)
+	b.ResetTimer()
+	for range b.N {
+		if _, err := s.ListScanResults(context.Background(), ListScanResultsOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkListScannedImages(b *testing.B) {
+	s := newTestScanService(b)
+	seedScanResults(b, s, 20000)
+	b.ResetTimer()
+	for range b.N {
+		if _, err := s.ListScannedImages(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScannedImageIDs(b *testing.B) {
+	s := newTestScanService(b)
+	seedScanResults(b, s, 20000)
+	b.ResetTimer()
+	for range b.N {
+		if _, err := s.ScannedImageIDs(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// upgradeLibssl3 upgrades libssl3 of the test BOM to 3.0.9-r0, which is still
+// affected by CVE-2023-0464.
+func upgradeLibssl3(report string) string {
+	return strings.ReplaceAll(report, "3.0.8-r0", "3.0.9-r0")
+}
+
+// upsertFindTestImages stores the test BOM for alpine, and for debian with
+// libssl3 upgraded.
+func upsertFindTestImages(t *testing.T, s *ScanService) {
+	t.Helper()
+	report, _ := readTestBOM(t)
+	if _, _, err := s.UpsertScanResult(context.Background(), "alpine", report, database.ScanMetadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.UpsertScanResult(context.Background(), "debian", upgradeLibssl3(report), database.ScanMetadata{}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindComponents(t *testing.T) {
+	s := newTestScanService(t)
+	upsertFindTestImages(t, s)
+
+	tests := []struct {
+		purl     string
+		expected []string
+	}{
+		{"pkg:apk/alpine/libssl3@3.0.8-r0", []string{"alpine:pkg:apk/alpine/libssl3@3.0.8-r0"}},
+		{"pkg:apk/alpine/libssl3@3.0.9-r0?arch=x86_64", []string{"debian:pkg:apk/alpine/libssl3@3.0.9-r0"}},
+		// Without a version every version of the package matches.
+		{"pkg:apk/alpine/libssl3", []string{
+			"alpine:pkg:apk/alpine/libssl3@3.0.8-r0",
+			"debian:pkg:apk/alpine/libssl3@3.0.9-r0",
+		}},
+		{"pkg:apk/alpine/libssl3@1.0.0", []string{}},
+		{"pkg:npm/lodash", []string{}},
+	}
+
+	for _, test := range tests {
+		components, err := s.FindComponents(context.Background(), test.purl)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		found := []string{}
+		for _, component := range components {
+			found = append(found, component.ImageID+":"+component.Purl)
+		}
+
+		if fmt.Sprint(found) != fmt.Sprint(test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.purl, test.expected, found)
+		}
+	}
+}
+
+func TestFindVulnerabilities(t *testing.T) {
+	s := newTestScanService(t)
+	upsertFindTestImages(t, s)
+
+	tests := []struct {
+		vulnerabilityId string
+		expected        []string
+	}{
+		{"CVE-2023-0464", []string{"alpine", "debian"}},
+		// The aliases of an advisory are indexed as well.
+		{"GHSA-xxxx-yyyy-zzzz", []string{"alpine", "debian"}},
+		{"CVE-2000-0001", []string{}},
+	}
+
+	for _, test := range tests {
+		vulnerabilities, err := s.FindVulnerabilities(context.Background(), test.vulnerabilityId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		found := []string{}
+		for _, vulnerability := range vulnerabilities {
+			if vulnerability.VulnerabilityID != test.vulnerabilityId {
+				t.Errorf("%s: unexpected vulnerability %s", test.vulnerabilityId, vulnerability.VulnerabilityID)
+			}
+
+			found = append(found, vulnerability.ImageID)
+		}
+
+		if fmt.Sprint(found) != fmt.Sprint(test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.vulnerabilityId, test.expected, found)
+		}
+	}
+}
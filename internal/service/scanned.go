@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+)
+
+// scannedImagesTTL bounds how long the set of scanned images is cached, as
+// reports uploaded to other replicas do not invalidate it.
+const scannedImagesTTL = 30 * time.Second
+
+// ScannedImage is an image having a ScanResult, without its report.
+type ScannedImage struct {
+	ImageID         string
+	UpdatedAt       time.Time
+	RescanRequested bool
+}
+
+type scannedImageCache struct {
+	mu       sync.Mutex
+	imageIDs map[string]bool
+	loadedAt time.Time
+}
+
+func (c *scannedImageCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.imageIDs = nil
+}
+
+// ListScannedImages returns the images having a ScanResult without loading
+// the reports.
+func (s *ScanService) ListScannedImages(ctx context.Context) ([]ScannedImage, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	scannedImages := []ScannedImage{}
+	res := s.db.WithContext(ctx).Model(&database.ScanResult{}).Select("image_id", "updated_at", "rescan_requested").Find(&scannedImages)
+	if res.Error != nil {
+		return nil, fmt.Errorf("error while listing scanned images: %w", res.Error)
+	}
+
+	return scannedImages, nil
+}
+
+// ScannedImageIDs returns the set of images having a ScanResult which is not
+// requested to be rescanned. The set is cached until a ScanResult is upserted
+// or deleted, so it is shared between callers and must not be modified.
+func (s *ScanService) ScannedImageIDs(ctx context.Context) (map[string]bool, error) {
+	c := &s.scannedImages
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The lock is held while loading, so an invalidation caused by a concurrent
+	// write cannot be overwritten by the set loaded before the write.
+	if c.imageIDs != nil && time.Since(c.loadedAt) < scannedImagesTTL {
+		return c.imageIDs, nil
+	}
+
+	scannedImages, err := s.ListScannedImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	imageIDs := make(map[string]bool, len(scannedImages))
+	for _, scannedImage := range scannedImages {
+		if !scannedImage.RescanRequested {
+			imageIDs[scannedImage.ImageID] = true
+		}
+	}
+
+	c.imageIDs = imageIDs
+	c.loadedAt = time.Now()
+	return imageIDs, nil
+}
+
+// RequestRescans requests the images last scanned before the given time to be
+// scanned again, and returns how many were requested.
+func (s *ScanService) RequestRescans(ctx context.Context, scannedBefore time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	res := s.db.WithContext(ctx).Model(&database.ScanResult{}).
+		Where("updated_at < ? AND rescan_requested = ?", scannedBefore, false).
+		UpdateColumn("rescan_requested", true)
+	if res.Error != nil {
+		return 0, fmt.Errorf("error while requesting rescans: %w", res.Error)
+	}
+
+	if res.RowsAffected > 0 {
+		s.scannedImages.invalidate()
+	}
+
+	return res.RowsAffected, nil
+}
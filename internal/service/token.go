@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	InvalidUploadToken = errors.New("invalid upload token")
+	ExpiredUploadToken = errors.New("expired upload token")
+	ReusedUploadToken  = errors.New("reused upload token")
+	// UploadTokenImageMismatch is an InvalidUploadToken issued for another
+	// image than the one of the report.
+	UploadTokenImageMismatch = fmt.Errorf("%w: issued for another image", InvalidUploadToken)
+)
+
+// UploadTokenTTL is how long a scan Job can upload its report after it was
+// created.
+const UploadTokenTTL = 2 * time.Hour
+
+type UploadTokenServiceInterface interface {
+	// Issue returns a token allowing a single upload of the report of the
+	// image.
+	Issue(id string, imageID string) (string, error)
+	// Verify checks that the token was issued for the image and was not used
+	// yet, without using it.
+	Verify(ctx context.Context, token string, imageID string) error
+	// Use checks that the token was issued for the image and marks it used.
+	// It is called once the report was stored, so that a rejected report or a
+	// failed upload does not spend the token.
+	Use(ctx context.Context, token string, imageID string) error
+	// PruneUsedTokens forgets the used tokens which expired, as they are
+	// rejected anyway.
+	PruneUsedTokens(ctx context.Context) (int64, error)
+}
+
+// UploadTokenService issues the tokens of the scan Jobs signed with HMAC-SHA256.
+// The used tokens are kept in the database, so that every replica rejects a
+// token used with any of them.
+type UploadTokenService struct {
+	db  *gorm.DB
+	key []byte
+	now func() time.Time
+}
+
+// NewUploadTokenService signs the tokens with the key. Without it a random key
+// is generated, and the tokens are only accepted by the replica issuing them.
+func NewUploadTokenService(db *gorm.DB, key []byte) (*UploadTokenService, error) {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate upload token key: %w", err)
+		}
+	}
+
+	return &UploadTokenService{
+		db:  db,
+		key: key,
+		now: time.Now,
+	}, nil
+}
+
+type uploadTokenClaims struct {
+	ID        string `json:"id"`
+	ImageID   string `json:"imageId"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+func (s *UploadTokenService) Issue(id string, imageID string) (string, error) {
+	payload, err := json.Marshal(uploadTokenClaims{
+		ID:        id,
+		ImageID:   imageID,
+		ExpiresAt: s.now().Add(UploadTokenTTL).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error while issuing upload token: %w", err)
+	}
+
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	return encodedPayload + "." + base64.RawURLEncoding.EncodeToString(s.sign(encodedPayload)), nil
+}
+
+func (s *UploadTokenService) Verify(ctx context.Context, token string, imageID string) error {
+	claims, err := s.verify(token, imageID)
+	if err != nil {
+		return err
+	}
+
+	var used int64
+	if err := s.db.WithContext(ctx).Model(&database.UsedUploadToken{}).Where("id = ?", claims.ID).Count(&used).Error; err != nil {
+		return fmt.Errorf("error while verifying upload token: %w", err)
+	}
+
+	if used > 0 {
+		return ReusedUploadToken
+	}
+
+	return nil
+}
+
+func (s *UploadTokenService) Use(ctx context.Context, token string, imageID string) error {
+	claims, err := s.verify(token, imageID)
+	if err != nil {
+		return err
+	}
+
+	res := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&database.UsedUploadToken{
+		ID:        claims.ID,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	})
+	if res.Error != nil {
+		return fmt.Errorf("error while using upload token: %w", res.Error)
+	}
+
+	if res.RowsAffected == 0 {
+		return ReusedUploadToken
+	}
+
+	return nil
+}
+
+// verify checks the signature, the image and the expiry of the token.
+func (s *UploadTokenService) verify(token string, imageID string) (*uploadTokenClaims, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, InvalidUploadToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, s.sign(encodedPayload)) {
+		return nil, InvalidUploadToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, InvalidUploadToken
+	}
+
+	claims := uploadTokenClaims{}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ID == "" {
+		return nil, InvalidUploadToken
+	}
+
+	if claims.ImageID != imageID {
+		return nil, UploadTokenImageMismatch
+	}
+
+	if !s.now().Before(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ExpiredUploadToken
+	}
+
+	return &claims, nil
+}
+
+func (s *UploadTokenService) sign(encodedPayload string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(encodedPayload))
+	return mac.Sum(nil)
+}
+
+func (s *UploadTokenService) PruneUsedTokens(ctx context.Context) (int64, error) {
+	res := s.db.WithContext(ctx).Where("expires_at < ?", s.now()).Delete(&database.UsedUploadToken{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("error while pruning used upload tokens: %w", res.Error)
+	}
+
+	return res.RowsAffected, nil
+}
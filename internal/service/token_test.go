@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestUploadTokenService(t *testing.T) *UploadTokenService {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB.SetMaxOpenConns(1)
+	if _, err := database.Migrate(context.Background(), db, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewUploadTokenService(db, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestUploadToken(t *testing.T) {
+	s := newTestUploadTokenService(t)
+	token, err := s.Issue("scan-1", "alpine@sha256:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Use(context.Background(), token, "nginx@sha256:2"); !errors.Is(err, UploadTokenImageMismatch) ||
+		!errors.Is(err, InvalidUploadToken) {
+		t.Errorf("expected a token of another image to be rejected, got %v", err)
+	}
+
+	if err := s.Use(context.Background(), token+"a", "alpine@sha256:1"); !errors.Is(err, InvalidUploadToken) {
+		t.Errorf("expected a tampered token to be rejected, got %v", err)
+	}
+
+	other := &UploadTokenService{db: s.db, key: []byte("other"), now: time.Now}
+	if err := other.Use(context.Background(), token, "alpine@sha256:1"); !errors.Is(err, InvalidUploadToken) {
+		t.Errorf("expected a token signed with another key to be rejected, got %v", err)
+	}
+
+	// Verifying the token does not use it, so that a rejected report does not
+	// spend the token of the Job.
+	for i := 0; i < 2; i++ {
+		if err := s.Verify(context.Background(), token, "alpine@sha256:1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Use(context.Background(), token, "alpine@sha256:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Verify(context.Background(), token, "alpine@sha256:1"); !errors.Is(err, ReusedUploadToken) {
+		t.Errorf("expected a used token to fail verification, got %v", err)
+	}
+
+	if err := s.Use(context.Background(), token, "alpine@sha256:1"); !errors.Is(err, ReusedUploadToken) {
+		t.Errorf("expected a used token to be rejected, got %v", err)
+	}
+
+	s.now = func() time.Time { return time.Now().Add(-UploadTokenTTL) }
+	expired, err := s.Issue("scan-2", "alpine@sha256:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.now = time.Now
+	if err := s.Use(context.Background(), expired, "alpine@sha256:1"); !errors.Is(err, ExpiredUploadToken) {
+		t.Errorf("expected an expired token to be rejected, got %v", err)
+	}
+
+	if pruned, err := s.PruneUsedTokens(context.Background()); err != nil || pruned != 0 {
+		t.Errorf("expected the used token to be kept until it expires, pruned %d (%v)", pruned, err)
+	}
+
+	s.now = func() time.Time { return time.Now().Add(UploadTokenTTL) }
+	if pruned, err := s.PruneUsedTokens(context.Background()); err != nil || pruned != 1 {
+		t.Errorf("expected the expired token to be pruned, pruned %d (%v)", pruned, err)
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+)
+
+// ImageVulnerabilities is the VulnerabilitySummary of an image together with
+// a namespace it was running in when it was scanned. Namespace is empty for
+// images scanned without triggers.
+type ImageVulnerabilities struct {
+	ImageID   string
+	Namespace string
+	database.VulnerabilitySummary
+}
+
+// ListImageVulnerabilities returns the summaries of every ScanResult, once for
+// every namespace of the image, from the most to the least vulnerable image.
+func (s *ScanService) ListImageVulnerabilities(ctx context.Context) ([]ImageVulnerabilities, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	columns := []string{"scan_results.image_id", "COALESCE(scan_triggers.namespace, '') AS namespace"}
+	for _, column := range summaryColumns {
+		columns = append(columns, "scan_results."+column)
+	}
+
+	imageVulnerabilities := []ImageVulnerabilities{}
+	res := s.db.WithContext(ctx).Model(&database.ScanResult{}).
+		Distinct(columns).
+		Joins("LEFT JOIN scan_triggers ON scan_triggers.image_id = scan_results.image_id").
+		Order("scan_results.critical_count DESC, scan_results.high_count DESC, scan_results.image_id, namespace").
+		Scan(&imageVulnerabilities)
+	if res.Error != nil {
+		return nil, fmt.Errorf("error while listing ImageVulnerabilities: %w", res.Error)
+	}
+
+	return imageVulnerabilities, nil
+}
+
+// LatestDatabaseVersion returns the newest vulnerability database version
+// used by the scans, or an empty string if no version is known.
+func (s *ScanService) LatestDatabaseVersion(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	var version string
+	res := s.db.WithContext(ctx).Model(&database.ScanResult{}).Select("COALESCE(MAX(database_version), '')").Scan(&version)
+	if res.Error != nil {
+		return "", fmt.Errorf("error while getting LatestDatabaseVersion: %w", res.Error)
+	}
+
+	return version, nil
+}
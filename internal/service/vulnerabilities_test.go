@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+)
+
+func TestListImageVulnerabilities(t *testing.T) {
+	s := newTestScanService(t)
+	report, _ := readTestBOM(t)
+	triggers := []database.ScanTrigger{
+		{Namespace: "default", Pod: "a", Container: "a"},
+		{Namespace: "default", Pod: "b", Container: "b"},
+		{Namespace: "kube-system", Pod: "c", Container: "c"},
+	}
+	metadata := database.ScanMetadata{DatabaseVersion: "2024-01-02T00:00:00Z"}
+	if _, _, err := s.UpsertScanResult(context.Background(), "alpine", report, metadata, triggers); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.UpsertScanResult(context.Background(), "debian", report, database.ScanMetadata{DatabaseVersion: "2024-01-01T00:00:00Z"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	imageVulnerabilities, err := s.ListImageVulnerabilities(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary := database.VulnerabilitySummary{
+		CriticalCount:  1,
+		HighCount:      1,
+		FixableCount:   1,
+		MaxCVSSScore:   9.8,
+		ComponentCount: 2,
+	}
+	expected := []ImageVulnerabilities{
+		{ImageID: "alpine", Namespace: "default", VulnerabilitySummary: summary},
+		{ImageID: "alpine", Namespace: "kube-system", VulnerabilitySummary: summary},
+		{ImageID: "debian", Namespace: "", VulnerabilitySummary: summary},
+	}
+	if !reflect.DeepEqual(imageVulnerabilities, expected) {
+		t.Errorf("unexpected image vulnerabilities: %+v", imageVulnerabilities)
+	}
+
+	version, err := s.LatestDatabaseVersion(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != "2024-01-02T00:00:00Z" {
+		t.Errorf("unexpected latest database version: %q", version)
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ImageUsage describes a container currently running an image together with
+// the workload owning its pod.
+type ImageUsage struct {
+	Namespace    string
+	Pod          string
+	Container    string
+	WorkloadKind string
+	WorkloadName string
+}
+
+type WorkloadServiceInterface interface {
+	ListImageUsages(ctx context.Context, imageIDs []string) (map[string][]ImageUsage, error)
+}
+
+type WorkloadService struct {
+	client client.Reader
+}
+
+func NewWorkloadService(client client.Reader) *WorkloadService {
+	return &WorkloadService{
+		client: client,
+	}
+}
+
+func (w *WorkloadService) ListImageUsages(ctx context.Context, imageIDs []string) (map[string][]ImageUsage, error) {
+	wanted := map[string]bool{}
+	for _, imageID := range imageIDs {
+		wanted[imageID] = true
+	}
+
+	podList := &corev1.PodList{}
+	if err := w.client.List(ctx, podList); err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	usages := map[string][]ImageUsage{}
+	for _, pod := range podList.Items {
+		statuses := slices.Concat(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses)
+		for _, containerStatus := range statuses {
+			if !wanted[containerStatus.ImageID] {
+				continue
+			}
+
+			kind, name := w.resolveWorkload(ctx, &pod)
+			usages[containerStatus.ImageID] = append(usages[containerStatus.ImageID], ImageUsage{
+				Namespace:    pod.Namespace,
+				Pod:          pod.Name,
+				Container:    containerStatus.Name,
+				WorkloadKind: kind,
+				WorkloadName: name,
+			})
+		}
+	}
+
+	return usages, nil
+}
+
+// resolveWorkload follows the controller references of a pod up to the
+// top-level workload, e.g. Pod -> ReplicaSet -> Deployment. Owners that cannot
+// be looked up are reported as they are.
+func (w *WorkloadService) resolveWorkload(ctx context.Context, pod *corev1.Pod) (string, string) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return "", ""
+	}
+
+	var parent client.Object
+	switch owner.Kind {
+	case "ReplicaSet":
+		parent = &appsv1.ReplicaSet{}
+	case "Job":
+		parent = &batchv1.Job{}
+	default:
+		return owner.Kind, owner.Name
+	}
+
+	key := types.NamespacedName{Namespace: pod.Namespace, Name: owner.Name}
+	if err := w.client.Get(ctx, key, parent); err != nil {
+		return owner.Kind, owner.Name
+	}
+
+	if parentOwner := metav1.GetControllerOf(parent); parentOwner != nil {
+		return parentOwner.Kind, parentOwner.Name
+	}
+
+	return owner.Kind, owner.Name
+}
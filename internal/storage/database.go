@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kerezsiz42/scanner-operator2/internal/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// loadBatchSize bounds the number of hashes in a single IN clause.
+const loadBatchSize = 1000
+
+// DatabaseReportStore keeps the reports in the report_blobs table.
+type DatabaseReportStore struct {
+	db *gorm.DB
+}
+
+func NewDatabaseReportStore(db *gorm.DB) *DatabaseReportStore {
+	return &DatabaseReportStore{
+		db: db,
+	}
+}
+
+func (d *DatabaseReportStore) Store(ctx context.Context, hash string, data []byte) error {
+	blob := database.ReportBlob{Hash: hash, Data: data}
+	if err := d.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&blob).Error; err != nil {
+		return fmt.Errorf("failed to store report %s: %w", hash, err)
+	}
+
+	return nil
+}
+
+func (d *DatabaseReportStore) Load(ctx context.Context, hashes []string) (map[string][]byte, error) {
+	reports := map[string][]byte{}
+	for start := 0; start < len(hashes); start += loadBatchSize {
+		batch := hashes[start:min(start+loadBatchSize, len(hashes))]
+		blobs := []database.ReportBlob{}
+		if err := d.db.WithContext(ctx).Where("hash IN ?", batch).Find(&blobs).Error; err != nil {
+			return nil, fmt.Errorf("failed to load reports: %w", err)
+		}
+
+		for _, blob := range blobs {
+			reports[blob.Hash] = blob.Data
+		}
+	}
+
+	return reports, nil
+}
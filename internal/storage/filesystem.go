@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FilesystemReportStore keeps every report in a file named after its hash,
+// e.g. <dir>/ab/abcdef....json.gz.
+type FilesystemReportStore struct {
+	dir string
+}
+
+func NewFilesystemReportStore(dir string) (*FilesystemReportStore, error) {
+	if dir == "" {
+		return nil, errors.New("report store path is not set")
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create report store directory: %w", err)
+	}
+
+	return &FilesystemReportStore{
+		dir: dir,
+	}, nil
+}
+
+func (f *FilesystemReportStore) path(hash string) string {
+	return filepath.Join(f.dir, hash[:min(2, len(hash))], hash+".json.gz")
+}
+
+// Store writes the report to a temporary file first, so that readers never
+// see a partially written report.
+func (f *FilesystemReportStore) Store(_ context.Context, hash string, data []byte) error {
+	path := f.path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to store report %s: %w", hash, err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), hash+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to store report %s: %w", hash, err)
+	}
+
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to store report %s: %w", hash, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to store report %s: %w", hash, err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to store report %s: %w", hash, err)
+	}
+
+	return nil
+}
+
+func (f *FilesystemReportStore) Load(_ context.Context, hashes []string) (map[string][]byte, error) {
+	reports := map[string][]byte{}
+	for _, hash := range hashes {
+		data, err := os.ReadFile(f.path(hash))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to load report %s: %w", hash, err)
+		}
+
+		reports[hash] = data
+	}
+
+	return reports, nil
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// reportPrefix is the key prefix of the reports in the bucket.
+const reportPrefix = "reports/"
+
+type S3Options struct {
+	Endpoint        string `json:"endpoint,omitempty"`
+	Bucket          string `json:"bucket,omitempty"`
+	Region          string `json:"region,omitempty"`
+	AccessKeyID     string `json:"accessKeyId,omitempty"`
+	SecretAccessKey string `json:"secretAccessKey,omitempty"`
+	// Insecure disables TLS, e.g. for an in-cluster MinIO.
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// S3ReportStore keeps the reports in a bucket of an S3 compatible object
+// storage.
+type S3ReportStore struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3ReportStore(options S3Options) (*S3ReportStore, error) {
+	if options.Endpoint == "" || options.Bucket == "" {
+		return nil, errors.New("S3 endpoint and bucket have to be set")
+	}
+
+	client, err := minio.New(options.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(options.AccessKeyID, options.SecretAccessKey, ""),
+		Secure: !options.Insecure,
+		Region: options.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	return &S3ReportStore{
+		client: client,
+		bucket: options.Bucket,
+	}, nil
+}
+
+func (s *S3ReportStore) key(hash string) string {
+	return reportPrefix + hash + ".json.gz"
+}
+
+func (s *S3ReportStore) Store(ctx context.Context, hash string, data []byte) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.key(hash), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/gzip",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store report %s: %w", hash, err)
+	}
+
+	return nil
+}
+
+func (s *S3ReportStore) Load(ctx context.Context, hashes []string) (map[string][]byte, error) {
+	reports := map[string][]byte{}
+	for _, hash := range hashes {
+		data, err := s.load(ctx, hash)
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to load report %s: %w", hash, err)
+		}
+
+		reports[hash] = data
+	}
+
+	return reports, nil
+}
+
+func (s *S3ReportStore) load(ctx context.Context, hash string) ([]byte, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, s.key(hash), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	defer object.Close()
+	return io.ReadAll(object)
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type ReportStoreType string
+
+const (
+	Database   ReportStoreType = "database"
+	Filesystem ReportStoreType = "filesystem"
+	S3         ReportStoreType = "s3"
+)
+
+// ReportStore holds the compressed reports addressed by the hash of their
+// content. Storing a report which exists already is not an error, and reports
+// missing from the store are left out of the result of Load.
+type ReportStore interface {
+	Store(ctx context.Context, hash string, data []byte) error
+	Load(ctx context.Context, hashes []string) (map[string][]byte, error)
+}
+
+// Options selects the ReportStore.
+type Options struct {
+	Type ReportStoreType `json:"type,omitempty"`
+	// Path is the directory of the filesystem store.
+	Path string    `json:"path,omitempty"`
+	S3   S3Options `json:"s3,omitempty"`
+}
+
+// GetReportStore returns the ReportStore selected by the options, defaulting
+// to the database. Reports stored in the database before switching to another
+// store remain readable.
+func GetReportStore(db *gorm.DB, options Options) (ReportStore, error) {
+	databaseStore := NewDatabaseReportStore(db)
+	switch options.Type {
+	case "", Database:
+		return databaseStore, nil
+	case Filesystem:
+		filesystemStore, err := NewFilesystemReportStore(options.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewFallbackReportStore(filesystemStore, databaseStore), nil
+	case S3:
+		s3Store, err := NewS3ReportStore(options.S3)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewFallbackReportStore(s3Store, databaseStore), nil
+	default:
+		return nil, fmt.Errorf("unsupported report store: %s", options.Type)
+	}
+}
+
+// fallbackReportStore stores reports in the primary store and loads the
+// reports missing from it from the fallback store.
+type fallbackReportStore struct {
+	primary  ReportStore
+	fallback ReportStore
+}
+
+func NewFallbackReportStore(primary ReportStore, fallback ReportStore) ReportStore {
+	return &fallbackReportStore{
+		primary:  primary,
+		fallback: fallback,
+	}
+}
+
+func (f *fallbackReportStore) Store(ctx context.Context, hash string, data []byte) error {
+	return f.primary.Store(ctx, hash, data)
+}
+
+func (f *fallbackReportStore) Load(ctx context.Context, hashes []string) (map[string][]byte, error) {
+	reports, err := f.primary.Load(ctx, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []string{}
+	for _, hash := range hashes {
+		if _, ok := reports[hash]; !ok {
+			missing = append(missing, hash)
+		}
+	}
+
+	if len(missing) == 0 {
+		return reports, nil
+	}
+
+	fallbackReports, err := f.fallback.Load(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for hash, data := range fallbackReports {
+		reports[hash] = data
+	}
+
+	return reports, nil
+}
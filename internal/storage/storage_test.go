@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is a minimal stand-in for an S3 compatible object storage, serving
+// path-style PUT and GET requests of objects.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := readObject(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		f.objects[r.URL.Path] = data
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			return
+		}
+
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", `"etag"`)
+		_, _ = w.Write(data)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// readObject reads the uploaded object, decoding the aws-chunked encoding used
+// by signed streaming uploads.
+func readObject(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	data := []byte{}
+	reader := bufio.NewReader(r.Body)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		sizeStr, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeStr, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		if size == 0 {
+			return data, nil
+		}
+
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return nil, err
+		}
+
+		data = append(data, chunk[:size]...)
+	}
+}
+
+func newTestS3ReportStore(t *testing.T) (*S3ReportStore, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s3Store, err := NewS3ReportStore(S3Options{
+		Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		Bucket:          "reports",
+		Region:          "us-east-1",
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+		Insecure:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s3Store, fake
+}
+
+func testReportStore(t *testing.T, reportStore ReportStore) {
+	t.Helper()
+	ctx := context.Background()
+	for range 2 {
+		if err := reportStore.Store(ctx, "abcdef", []byte("report")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reports, err := reportStore.Load(ctx, []string{"abcdef", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) != 1 || string(reports["abcdef"]) != "report" {
+		t.Errorf("unexpected reports: %v", reports)
+	}
+}
+
+func TestFilesystemReportStore(t *testing.T) {
+	filesystemStore, err := NewFilesystemReportStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testReportStore(t, filesystemStore)
+}
+
+func TestS3ReportStore(t *testing.T) {
+	s3Store, fake := newTestS3ReportStore(t)
+	testReportStore(t, s3Store)
+
+	if _, ok := fake.objects["/reports/reports/abcdef.json.gz"]; !ok {
+		t.Errorf("unexpected objects: %v", fake.objects)
+	}
+}
+
+func TestFallbackReportStore(t *testing.T) {
+	ctx := context.Background()
+	primary, err := NewFilesystemReportStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fallback, err := NewFilesystemReportStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fallback.Store(ctx, "old", []byte("old report")); err != nil {
+		t.Fatal(err)
+	}
+
+	reportStore := &fallbackReportStore{primary: primary, fallback: fallback}
+	testReportStore(t, reportStore)
+
+	reports, err := reportStore.Load(ctx, []string{"abcdef", "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) != 2 || string(reports["old"]) != "old report" {
+		t.Errorf("expected the report to be loaded from the fallback store: %v", reports)
+	}
+}
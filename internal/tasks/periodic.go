@@ -0,0 +1,46 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// PeriodicTask runs a function on an interval as a Runnable of the manager,
+// first when the manager starts. The failures are logged, and the function is
+// retried on the next tick.
+type PeriodicTask struct {
+	Name     string
+	Interval time.Duration
+	// LeaderElection makes the task run on the leader only. The tasks which
+	// must not run concurrently on several replicas, like the maintenance of
+	// the database, have to set it.
+	LeaderElection bool
+	Run            func(ctx context.Context) error
+	Logger         logr.Logger
+}
+
+func (t *PeriodicTask) NeedLeaderElection() bool {
+	return t.LeaderElection
+}
+
+func (t *PeriodicTask) Start(ctx context.Context) error {
+	logger := t.Logger.WithValues("task", t.Name)
+	logger.Info("starting periodic task", "interval", t.Interval, "leaderElection", t.LeaderElection)
+
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.Run(ctx); err != nil && ctx.Err() == nil {
+			logger.Error(err, "periodic task failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
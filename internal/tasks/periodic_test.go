@@ -0,0 +1,51 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var _ manager.LeaderElectionRunnable = &PeriodicTask{}
+
+func TestPeriodicTask(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := make(chan struct{}, 10)
+	task := &PeriodicTask{
+		Name:           "test",
+		Interval:       10 * time.Millisecond,
+		LeaderElection: true,
+		Run: func(ctx context.Context) error {
+			runs <- struct{}{}
+			// The failures do not stop the task.
+			return errors.New("failed")
+		},
+		Logger: logr.Discard(),
+	}
+
+	if !task.NeedLeaderElection() {
+		t.Error("expected the task to need leader election")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- task.Start(ctx)
+	}()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-runs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the task did not run")
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
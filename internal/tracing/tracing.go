@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is the name the spans of the operator are exported with.
+const ServiceName = "scanner-operator"
+
+// Options configures the export of the spans to an OpenTelemetry collector.
+type Options struct {
+	// Endpoint is the host:port of the OTLP gRPC receiver of the collector.
+	// Tracing is disabled if empty.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Insecure disables TLS, e.g. for a collector in the same cluster.
+	Insecure bool `json:"insecure,omitempty"`
+	// SampleRatio is the ratio of the traces started by the operator which
+	// are sampled. The traces continued from a sampled parent are sampled.
+	SampleRatio float64 `json:"sampleRatio"`
+}
+
+func (o Options) Enabled() bool {
+	return o.Endpoint != ""
+}
+
+// Setup installs the global TracerProvider exporting the spans over OTLP and
+// the W3C trace context propagator. Nothing is installed when tracing is
+// disabled, so the spans started by the operator are not recorded. The
+// returned function flushes the spans left and stops the exporter.
+func Setup(ctx context.Context, options Options) (func(context.Context) error, error) {
+	if !options.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	clientOptions := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(options.Endpoint)}
+	if options.Insecure {
+		clientOptions = append(clientOptions, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, clientOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// Traceparent returns the W3C traceparent header of the span of the context,
+// or an empty string if there is none, e.g. when tracing is disabled.
+func Traceparent(ctx context.Context) string {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ""
+	}
+
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}